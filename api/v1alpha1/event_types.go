@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -39,10 +40,71 @@ type EventSpec struct {
 type EventStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
+
+	// Phase of the Event dispatching
+	// +optional
+	Phase EventPhaseType `json:"phase,omitempty"`
+
+	// PlayRef is referencing the Play started by this Event
+	// +optional
+	PlayRef *corev1.ObjectReference `json:"playRef,omitempty"`
+
+	// Conditions describe the current state of the Event
+	// +optional
+	Conditions []Condition `json:"conditions,omitempty"`
+
+	// The generation of the Event observed by the controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+// EventPhaseType defines the phase of an Event
+type EventPhaseType string
+
+// These are valid phases of an Event.
+const (
+	// EventPhasePending means the Play of the Event hasn't been created yet.
+	EventPhasePending EventPhaseType = "Pending"
+	// EventPhaseDispatched means the Play of the Event has been created.
+	EventPhaseDispatched EventPhaseType = "Dispatched"
+	// EventPhaseRejected means the Event can't start a Play, e.g. because its Movie doesn't exist.
+	// Rejected Events are not retried until their spec changes.
+	EventPhaseRejected EventPhaseType = "Rejected"
+	// EventPhaseFailed means creating the Play of the Event failed. Creation will be retried.
+	EventPhaseFailed EventPhaseType = "Failed"
+)
+
+// Finished returns true if no further processing of the Event is needed. Every Event
+// starts at most one Play, while rejected Events are reconsidered when their spec changes.
+func (e *Event) Finished() bool {
+	return e.Status.Phase == EventPhaseDispatched ||
+		(e.Status.Phase == EventPhaseRejected && e.Status.ObservedGeneration == e.Generation)
 }
 
+// These are valid conditions of an Event.
+const (
+	// EventConditionDispatched is True when the Play of the Event has been created.
+	EventConditionDispatched = "Dispatched"
+)
+
+// These are reasons of the Event conditions.
+const (
+	// EventReasonPlayCreated means the Play of the Event has been created.
+	EventReasonPlayCreated = "PlayCreated"
+	// EventReasonMovieNotFound means the Movie referenced by the Event doesn't exist.
+	EventReasonMovieNotFound = "MovieNotFound"
+	// EventReasonPlayCreateFailed means the Play of the Event couldn't be created.
+	EventReasonPlayCreateFailed = "PlayCreateFailed"
+	// EventReasonPlayConflict means a Play with the name of the Event's Play was started by something else.
+	EventReasonPlayConflict = "PlayConflict"
+)
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Movie",type=string,JSONPath=`.spec.movie`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Play",type=string,JSONPath=`.status.playRef.name`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Event is the Schema for the events API
 type Event struct {
@@ -0,0 +1,76 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// SetupWebhookWithManager registers webhooks of Events
+func (r *Event) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	// Reading directly from the API server makes sure Events of just created Movies aren't rejected
+	registerValidatingWebhook(mgr, "/validate-core-kuberik-io-v1alpha1-event", r, webhookValidation{reader: mgr.GetAPIReader()})
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-core-kuberik-io-v1alpha1-event,mutating=false,failurePolicy=fail,groups=core.kuberik.io,resources=events,versions=v1alpha1,name=vevent.core.kuberik.io
+
+var _ webhookValidator = &Event{}
+
+func (r *Event) validateCreate(ctx context.Context, v webhookValidation) error {
+	return r.validate(ctx, v)
+}
+
+func (r *Event) validateUpdate(ctx context.Context, v webhookValidation, old runtime.Object) error {
+	if oldEvent, ok := old.(*Event); ok && oldEvent.Spec.Movie == r.Spec.Movie {
+		// Movie might have been deleted after the Event was created
+		return nil
+	}
+	return r.validate(ctx, v)
+}
+
+func (r *Event) validate(ctx context.Context, v webhookValidation) error {
+	errs := validateEventMovie(ctx, v.reader, r)
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("Event").GroupKind(), r.Name, errs)
+}
+
+func validateEventMovie(ctx context.Context, reader client.Reader, event *Event) field.ErrorList {
+	moviePath := field.NewPath("spec", "movie")
+	if event.Spec.Movie == "" {
+		return field.ErrorList{field.Required(moviePath, "")}
+	}
+	err := reader.Get(ctx, types.NamespacedName{Name: event.Spec.Movie, Namespace: event.Namespace}, &Movie{})
+	if apierrors.IsNotFound(err) {
+		return field.ErrorList{field.NotFound(moviePath, event.Spec.Movie)}
+	}
+	if err != nil {
+		return field.ErrorList{field.InternalError(moviePath, err)}
+	}
+	return nil
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -29,12 +30,51 @@ type MovieSpec struct {
 	// Important: Run "make" to regenerate code after modifying this file
 
 	Template PlayTemplate `json:"template"`
+
+	// The number of failed finished Plays to retain.
+	// All of them are retained if limit is not set.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	FailedJobsHistoryLimit *int32 `json:"failedJobsHistoryLimit,omitempty"`
+
+	// The number of successful finished Plays to retain.
+	// All of them are retained if limit is not set.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	SuccessfulJobsHistoryLimit *int32 `json:"successfulJobsHistoryLimit,omitempty"`
+
+	// Schedule in Cron format for creating Plays of the Movie periodically.
+	// Plays are created only from Events if schedule is not set.
 	// +optional
-	FailedJobsHistoryLimit int `json:"failedJobsHistoryLimit"`
+	Schedule string `json:"schedule,omitempty"`
+
+	// Specifies how to treat concurrent Plays created by the schedule.
+	// Defaults to Allow.
+	// +optional
+	ConcurrencyPolicy ConcurrencyPolicy `json:"concurrencyPolicy,omitempty"`
+
+	// Deadline in seconds for starting a Play if it misses its scheduled time for any reason.
 	// +optional
-	SuccessfulJobsHistoryLimit int `json:"successfulJobsHistoryLimit"`
+	// +kubebuilder:validation:Minimum=0
+	StartingDeadlineSeconds *int64 `json:"startingDeadlineSeconds,omitempty"`
 }
 
+// ConcurrencyPolicy describes how scheduled Plays of a Movie are handled.
+// +kubebuilder:validation:Enum=Allow;Forbid;Replace
+type ConcurrencyPolicy string
+
+const (
+	// AllowConcurrent allows scheduled Plays to run concurrently.
+	AllowConcurrent ConcurrencyPolicy = "Allow"
+
+	// ForbidConcurrent forbids concurrent runs, skipping next run if previous
+	// hasn't finished yet.
+	ForbidConcurrent ConcurrencyPolicy = "Forbid"
+
+	// ReplaceConcurrent cancels currently running Play and replaces it with a new one.
+	ReplaceConcurrent ConcurrencyPolicy = "Replace"
+)
+
 // PlayTemplate defines a template of Play to be created from a Movie
 type PlayTemplate struct {
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -45,10 +85,68 @@ type PlayTemplate struct {
 type MovieStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
+
+	// A list of pointers to currently running Plays.
+	// +optional
+	Active []corev1.ObjectReference `json:"active,omitempty"`
+
+	// Information when was the last time a Play was successfully scheduled.
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+
+	// The number of successful finished Plays retained by the history limit.
+	// +optional
+	RetainedSuccessfulPlays int32 `json:"retainedSuccessfulPlays,omitempty"`
+
+	// The number of failed finished Plays retained by the history limit.
+	// +optional
+	RetainedFailedPlays int32 `json:"retainedFailedPlays,omitempty"`
+
+	// The number of currently running Plays.
+	// +optional
+	Running int32 `json:"running,omitempty"`
+
+	// Records of the most recent Plays, ordered from the newest one.
+	// +optional
+	History []PlayRecord `json:"history,omitempty"`
+
+	// Percentage of successful Plays among the finished ones in history.
+	// +optional
+	SuccessRate *int32 `json:"successRate,omitempty"`
+
+	// A pointer to the last Play which finished successfully.
+	// +optional
+	LastSuccessfulPlay *corev1.ObjectReference `json:"lastSuccessfulPlay,omitempty"`
+}
+
+// PlayRecord describes a run of a Movie
+type PlayRecord struct {
+	// Name of the Play
+	Name string `json:"name"`
+
+	// Phase of the Play
+	Phase PlayPhaseType `json:"phase,omitempty"`
+
+	// Represents time when the Play started its execution.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// Represents time when the Play finished its execution.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Duration of the Play execution
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.spec.schedule`
+// +kubebuilder:printcolumn:name="Running",type=integer,JSONPath=`.status.running`
+// +kubebuilder:printcolumn:name="Success Rate",type=integer,JSONPath=`.status.successRate`
+// +kubebuilder:printcolumn:name="Last Success",type=string,JSONPath=`.status.lastSuccessfulPlay.name`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Movie is the Schema for the movies API
 type Movie struct {
@@ -0,0 +1,69 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// SetupWebhookWithManager registers webhooks of Movies. `when` expressions of frames
+// are validated with the given validator.
+func (r *Movie) SetupWebhookWithManager(mgr ctrl.Manager, when ExpressionValidator) error {
+	if when == nil {
+		return fmt.Errorf("validator of when expressions is required")
+	}
+	registerValidatingWebhook(mgr, "/validate-core-kuberik-io-v1alpha1-movie", r, webhookValidation{when: when})
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-core-kuberik-io-v1alpha1-movie,mutating=true,failurePolicy=fail,groups=core.kuberik.io,resources=movies,verbs=create;update,versions=v1alpha1,name=mmovie.core.kuberik.io
+
+var _ webhook.Defaulter = &Movie{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (r *Movie) Default() {
+	defaultPlaySpec(&r.Spec.Template.Spec)
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-core-kuberik-io-v1alpha1-movie,mutating=false,failurePolicy=fail,groups=core.kuberik.io,resources=movies,versions=v1alpha1,name=vmovie.core.kuberik.io
+
+var _ webhookValidator = &Movie{}
+
+func (r *Movie) validateCreate(ctx context.Context, v webhookValidation) error {
+	return r.validate(v)
+}
+
+func (r *Movie) validateUpdate(ctx context.Context, v webhookValidation, old runtime.Object) error {
+	return r.validate(v)
+}
+
+func (r *Movie) validate(v webhookValidation) error {
+	errs := validatePlaySpec(&r.Spec.Template.Spec, field.NewPath("spec", "template", "spec"), v.when)
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("Movie").GroupKind(), r.Name, errs)
+}
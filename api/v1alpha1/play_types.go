@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -29,6 +30,33 @@ type PlaySpec struct {
 	// Important: Run "make" to regenerate code after modifying this file
 
 	Screenplays []Screenplay `json:"screenplays"`
+
+	// Vars are available to all the frames of the Play
+	// +optional
+	Vars []Var `json:"vars,omitempty"`
+
+	// VolumeClaimTemplates describe PersistentVolumeClaims which are provisioned for the Play.
+	// Containers of all the frames can mount them by names of the templates.
+	// +optional
+	VolumeClaimTemplates []corev1.PersistentVolumeClaim `json:"volumeClaimTemplates,omitempty"`
+
+	// FailFast cancels all the frames which are still running once any frame of the Play fails
+	// +optional
+	FailFast bool `json:"failFast,omitempty"`
+
+	// Timeout limits the duration of the Play. Once it expires, frames of scenes which
+	// didn't finish fail and the closing credits are played.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// Suspend stops the Play from starting new frames. Frames which are already running finish.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// Cancel stops the Play. Jobs of running frames are deleted, remaining frames are cancelled
+	// and the closing credits are played. Cancelled Play can't be resumed.
+	// +optional
+	Cancel bool `json:"cancel,omitempty"`
 }
 
 // PlayStatus defines the observed state of Play
@@ -36,31 +64,93 @@ type PlayStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
+	// Frames describe the execution of the frames by their IDs
+	// +optional
 	Frames map[string]FrameStatus `json:"frames,omitempty"`
 	Phase  PlayPhaseType          `json:"phase,omitempty"`
+
+	// Represents time when the play started its execution.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// Represents time when the play finished its execution.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Conditions describe the current state of the play
+	// +optional
+	Conditions []Condition `json:"conditions,omitempty"`
+
+	// Number of times the failed play was rerun
+	// +optional
+	Reruns int32 `json:"reruns,omitempty"`
+
+	// Value of the rerun annotation which started the last rerun
+	// +optional
+	LastRerun string `json:"lastRerun,omitempty"`
 }
 
-// SetFrameStatus sets result of a frame
-func (ps *PlayStatus) SetFrameStatus(frameID string, result FrameStatus) {
+// SetFrameStatus sets status of a frame
+func (ps *PlayStatus) SetFrameStatus(frameID string, status FrameStatus) {
 	if ps.Frames == nil {
 		ps.Frames = make(map[string]FrameStatus)
 	}
-	ps.Frames[frameID] = result
+	ps.Frames[frameID] = status
+}
+
+// SetFrameState sets state of a frame. Start and completion times
+// of the frame are recorded if they're not set yet.
+func (ps *PlayStatus) SetFrameState(frameID string, state FrameState) {
+	status := ps.Frames[frameID]
+	status.State = state
+	now := metav1.Now()
+	if state != FrameStatePending && state != FrameStateSkipped && status.StartTime == nil {
+		status.StartTime = &now
+	}
+	if state.Finished() && status.CompletionTime == nil {
+		status.CompletionTime = &now
+	}
+	ps.SetFrameStatus(frameID, status)
+}
+
+// FrameState returns state of a frame. Frames without a status are pending.
+func (ps *PlayStatus) FrameState(frameID string) FrameState {
+	if status, ok := ps.Frames[frameID]; ok && status.State != "" {
+		return status.State
+	}
+	return FrameStatePending
+}
+
+// FrameFailed checks if a frame failed and its failure isn't allowed
+func (ps *PlayStatus) FrameFailed(frameID string) bool {
+	status, ok := ps.Frames[frameID]
+	return ok && status.State == FrameStateFailed && !status.FailureAllowed
 }
 
 // Failed checks if a play failed
 func (ps *PlayStatus) Failed() bool {
-	if ps.Frames == nil {
-		return false
-	}
-	for _, r := range ps.Frames {
-		if r == FrameStatusFailed {
+	for frameID := range ps.Frames {
+		if ps.FrameFailed(frameID) {
 			return true
 		}
 	}
 	return false
 }
 
+// Finished checks if a play ended its execution
+func (ps *PlayStatus) Finished() bool {
+	switch ps.Phase {
+	case PlayPhaseComplete, PlayPhaseFailed, PlayPhaseError, PlayPhaseCancelled:
+		return true
+	}
+	return false
+}
+
+// Successful checks if a play finished its execution successfully
+func (ps *PlayStatus) Successful() bool {
+	return ps.Phase == PlayPhaseComplete
+}
+
 // PlayPhaseType defines the phase of a Play
 type PlayPhaseType string
 
@@ -78,6 +168,48 @@ const (
 	PlayPhaseCreated PlayPhaseType = "Created"
 	// PlayPhaseError means the play ended because of an error.
 	PlayPhaseError PlayPhaseType = "Error"
+	// PlayPhaseCancelled means the play was cancelled before it finished.
+	PlayPhaseCancelled PlayPhaseType = "Cancelled"
+)
+
+// These are valid conditions of a play.
+const (
+	// PlayConditionProvisioned is True when resources of the main screenplay have been provisioned.
+	PlayConditionProvisioned = "Provisioned"
+	// PlayConditionOpeningCreditsDone is True when all opening credits of the main screenplay finished.
+	PlayConditionOpeningCreditsDone = "OpeningCreditsDone"
+	// PlayConditionScenesDone is True when all scenes of the main screenplay finished or were skipped.
+	PlayConditionScenesDone = "ScenesDone"
+	// PlayConditionClosingCreditsDone is True when all closing credits of the main screenplay finished.
+	PlayConditionClosingCreditsDone = "ClosingCreditsDone"
+	// PlayConditionDeprovisioned is True when resources of the main screenplay have been deprovisioned.
+	PlayConditionDeprovisioned = "Deprovisioned"
+	// PlayConditionReady is True when the play completed successfully.
+	PlayConditionReady = "Ready"
+	// PlayConditionError is True when the play ended because of an error.
+	PlayConditionError = "Error"
+)
+
+// These are reasons of the play conditions.
+const (
+	// PlayReasonPending means the step of the play hasn't started yet.
+	PlayReasonPending = "Pending"
+	// PlayReasonInProgress means the step of the play is in progress.
+	PlayReasonInProgress = "InProgress"
+	// PlayReasonSucceeded means the step of the play finished successfully.
+	PlayReasonSucceeded = "Succeeded"
+	// PlayReasonFailed means some of the frames played in the step of the play failed.
+	PlayReasonFailed = "Failed"
+	// PlayReasonSkipped means the step of the play was skipped because of an earlier failure.
+	PlayReasonSkipped = "Skipped"
+	// PlayReasonInvalidSpec means the play can't be played because of its spec.
+	PlayReasonInvalidSpec = "InvalidSpec"
+	// PlayReasonProvisionFailed means resources of the play couldn't be provisioned.
+	PlayReasonProvisionFailed = "ProvisionFailed"
+	// PlayReasonSuspended means the play doesn't start new frames because it's suspended.
+	PlayReasonSuspended = "Suspended"
+	// PlayReasonCancelled means the play was cancelled.
+	PlayReasonCancelled = "Cancelled"
 )
 
 // +kubebuilder:object:root=true
@@ -102,6 +234,9 @@ func (p *Play) Frame(frameID string) *Frame {
 				}
 			}
 		}
+		if screenplay.Credits == nil {
+			continue
+		}
 		for fi, frame := range screenplay.Credits.Opening {
 			if frame.ID == frameID {
 				return &p.Spec.Screenplays[spi].Credits.Opening[fi]
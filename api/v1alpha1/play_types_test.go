@@ -65,8 +65,8 @@ func TestPlayStatusFailed(t *testing.T) {
 
 	status = PlayStatus{
 		Frames: map[string]FrameStatus{
-			"a": FrameStatusSuccessful,
-			"b": FrameStatusSuccessful,
+			"a": {State: FrameStateSucceeded},
+			"b": {State: FrameStateSucceeded},
 		},
 	}
 	if status.Failed() {
@@ -75,12 +75,33 @@ func TestPlayStatusFailed(t *testing.T) {
 
 	status = PlayStatus{
 		Frames: map[string]FrameStatus{
-			"a": FrameStatusSuccessful,
-			"b": FrameStatusSuccessful,
-			"c": FrameStatusFailed,
+			"a": {State: FrameStateSucceeded},
+			"b": {State: FrameStateSucceeded},
+			"c": {State: FrameStateFailed},
 		},
 	}
 	if !status.Failed() {
 		t.Errorf("Status is not failed but should be")
 	}
 }
+
+func TestGetStoryFrame(t *testing.T) {
+	play := Play{
+		Spec: PlaySpec{
+			Screenplays: []Screenplay{{
+				Name: "main",
+			}, {
+				Name: "story",
+				Scenes: []Scene{{
+					Frames: []Frame{{
+						Name: "nested",
+						ID:   "a",
+					}},
+				}},
+			}},
+		},
+	}
+	if f := play.Frame("a"); f == nil || f.Name != "nested" {
+		t.Errorf("Failed to retrieve frame of a nested screenplay")
+	}
+}
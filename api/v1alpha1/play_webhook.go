@@ -0,0 +1,378 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// MainScreenplayName is the name of the Screenplay which is played when a Play starts
+const MainScreenplayName = "main"
+
+var defaultBackoffLimit int32 = 0
+
+// SetupWebhookWithManager registers webhooks of Plays. `when` expressions of frames
+// are validated with the given validator.
+func (r *Play) SetupWebhookWithManager(mgr ctrl.Manager, when ExpressionValidator) error {
+	if when == nil {
+		return fmt.Errorf("validator of when expressions is required")
+	}
+	registerValidatingWebhook(mgr, "/validate-core-kuberik-io-v1alpha1-play", r, webhookValidation{when: when})
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-core-kuberik-io-v1alpha1-play,mutating=true,failurePolicy=fail,groups=core.kuberik.io,resources=plays,verbs=create;update,versions=v1alpha1,name=mplay.core.kuberik.io
+
+var _ webhook.Defaulter = &Play{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (r *Play) Default() {
+	defaultPlaySpec(&r.Spec)
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-core-kuberik-io-v1alpha1-play,mutating=false,failurePolicy=fail,groups=core.kuberik.io,resources=plays,versions=v1alpha1,name=vplay.core.kuberik.io
+
+var _ webhookValidator = &Play{}
+
+func (r *Play) validateCreate(ctx context.Context, v webhookValidation) error {
+	return r.validate(v)
+}
+
+func (r *Play) validateUpdate(ctx context.Context, v webhookValidation, old runtime.Object) error {
+	if oldPlay, ok := old.(*Play); ok && oldPlay.Spec.Cancel && !r.Spec.Cancel {
+		return apierrors.NewInvalid(GroupVersion.WithKind("Play").GroupKind(), r.Name, field.ErrorList{
+			field.Forbidden(field.NewPath("spec", "cancel"), "cancelled play can't be resumed"),
+		})
+	}
+	return r.validate(v)
+}
+
+func (r *Play) validate(v webhookValidation) error {
+	errs := validatePlaySpec(&r.Spec, field.NewPath("spec"), v.when)
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("Play").GroupKind(), r.Name, errs)
+}
+
+// defaultPlaySpec fills in the defaults of the Jobs created for the actions of the Play
+func defaultPlaySpec(spec *PlaySpec) {
+	for i := range spec.Screenplays {
+		screenplay := &spec.Screenplays[i]
+		for j := range screenplay.Scenes {
+			defaultFrames(screenplay.Scenes[j].Frames)
+		}
+		if screenplay.Credits != nil {
+			defaultFrames(screenplay.Credits.Opening)
+			defaultFrames(screenplay.Credits.Closing)
+		}
+	}
+}
+
+func defaultFrames(frames []Frame) {
+	for i := range frames {
+		action := frames[i].Action
+		if action == nil {
+			continue
+		}
+		if action.BackoffLimit == nil {
+			backoffLimit := defaultBackoffLimit
+			action.BackoffLimit = &backoffLimit
+		}
+		if action.Template.Spec.RestartPolicy == "" {
+			action.Template.Spec.RestartPolicy = corev1.RestartPolicyNever
+		}
+	}
+}
+
+// validatePlaySpec validates structure of the Screenplays of a Play
+func validatePlaySpec(spec *PlaySpec, path *field.Path, when ExpressionValidator) field.ErrorList {
+	errs := field.ErrorList{}
+	screenplaysPath := path.Child("screenplays")
+
+	screenplays := make(map[string]bool)
+	for i, screenplay := range spec.Screenplays {
+		if screenplays[screenplay.Name] {
+			errs = append(errs, field.Duplicate(screenplaysPath.Index(i).Child("name"), screenplay.Name))
+		}
+		screenplays[screenplay.Name] = true
+	}
+	if !screenplays[MainScreenplayName] {
+		errs = append(errs, field.Required(screenplaysPath, "screenplay named 'main' is required"))
+	}
+	errs = append(errs, validateVars(spec.Vars, path.Child("vars"))...)
+	errs = append(errs, validateProvision(spec, path)...)
+	errs = append(errs, validateStoryReferences(spec, path)...)
+	claims := make(map[string]bool)
+	for i, template := range spec.VolumeClaimTemplates {
+		namePath := path.Child("volumeClaimTemplates").Index(i).Child("metadata", "name")
+		if template.Name == "" {
+			errs = append(errs, field.Required(namePath, "name of the volume claim template is required"))
+		}
+		if claims[template.Name] {
+			errs = append(errs, field.Duplicate(namePath, template.Name))
+		}
+		claims[template.Name] = true
+	}
+
+	for i, screenplay := range spec.Screenplays {
+		screenplayPath := screenplaysPath.Index(i)
+		errs = append(errs, validateVars(screenplay.Vars, screenplayPath.Child("vars"))...)
+		for j, scene := range screenplay.Scenes {
+			scenePath := screenplayPath.Child("scenes").Index(j)
+			frameNames := make(map[string]bool)
+			for k, frame := range scene.Frames {
+				framePath := scenePath.Child("frames").Index(k)
+				if frame.Name != "" && frameNames[frame.Name] {
+					errs = append(errs, field.Duplicate(framePath.Child("name"), frame.Name))
+				}
+				frameNames[frame.Name] = true
+				errs = append(errs, validateFrame(frame, framePath, screenplays, when)...)
+			}
+		}
+		if err := screenplay.ValidateDependencies(); err != nil {
+			errs = append(errs, field.Invalid(screenplayPath.Child("scenes"), screenplay.Name, err.Error()))
+		}
+		if screenplay.Credits != nil {
+			creditsPath := screenplayPath.Child("credits")
+			for k, frame := range screenplay.Credits.Opening {
+				errs = append(errs, validateFrame(frame, creditsPath.Child("opening").Index(k), screenplays, when)...)
+			}
+			for k, frame := range screenplay.Credits.Closing {
+				errs = append(errs, validateFrame(frame, creditsPath.Child("closing").Index(k), screenplays, when)...)
+			}
+		}
+	}
+	return errs
+}
+
+// validateProvision checks that resources provisioned by the screenplays don't share names, as all of them
+// are suffixed with name of the Play and resources of a screenplay are visible to its stories
+func validateProvision(spec *PlaySpec, path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	provisioned := make(map[string]bool)
+	for _, template := range spec.VolumeClaimTemplates {
+		provisioned["PersistentVolumeClaim/"+template.Name] = true
+	}
+	for i, screenplay := range spec.Screenplays {
+		for j, raw := range screenplay.Provision.Resources {
+			if len(raw.Raw) == 0 {
+				continue
+			}
+			resourcePath := path.Child("screenplays").Index(i).Child("provision", "resources").Index(j)
+			object := metav1.PartialObjectMetadata{}
+			if err := json.Unmarshal(raw.Raw, &object); err != nil {
+				errs = append(errs, field.Invalid(resourcePath, string(raw.Raw), err.Error()))
+				continue
+			}
+			key := object.Kind + "/" + object.Name
+			if provisioned[key] {
+				errs = append(errs, field.Duplicate(resourcePath, key))
+			}
+			provisioned[key] = true
+		}
+	}
+	return errs
+}
+
+// validateStoryReferences checks that every screenplay is played by a single story,
+// since frames of a screenplay share their statuses with all the stories playing it
+func validateStoryReferences(spec *PlaySpec, path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	referenced := make(map[string]bool)
+	reference := func(frame Frame, framePath *field.Path) {
+		if frame.Story == nil {
+			return
+		}
+		if referenced[*frame.Story] {
+			errs = append(errs, field.Duplicate(framePath.Child("story"), *frame.Story))
+		}
+		referenced[*frame.Story] = true
+	}
+	for i, screenplay := range spec.Screenplays {
+		screenplayPath := path.Child("screenplays").Index(i)
+		for j, scene := range screenplay.Scenes {
+			for k, frame := range scene.Frames {
+				reference(frame, screenplayPath.Child("scenes").Index(j).Child("frames").Index(k))
+			}
+		}
+		if screenplay.Credits != nil {
+			for k, frame := range screenplay.Credits.Opening {
+				reference(frame, screenplayPath.Child("credits", "opening").Index(k))
+			}
+			for k, frame := range screenplay.Credits.Closing {
+				reference(frame, screenplayPath.Child("credits", "closing").Index(k))
+			}
+		}
+	}
+	return errs
+}
+
+func validateFrame(frame Frame, path *field.Path, screenplays map[string]bool, when ExpressionValidator) field.ErrorList {
+	errs := field.ErrorList{}
+	switch {
+	case frame.Action == nil && frame.Story == nil:
+		errs = append(errs, field.Required(path, "either action or story is required"))
+	case frame.Action != nil && frame.Story != nil:
+		errs = append(errs, field.Invalid(path, frame.Name, "action and story are mutually exclusive"))
+	case frame.Story != nil && !screenplays[*frame.Story]:
+		errs = append(errs, field.NotFound(path.Child("story"), *frame.Story))
+	}
+	if frame.Matrix != nil {
+		errs = append(errs, validateMatrix(frame, path.Child("matrix"))...)
+	}
+	if frame.ForEach != "" {
+		forEachPath := path.Child("forEach")
+		if frame.Action == nil {
+			errs = append(errs, field.Invalid(forEachPath, frame.Name, "only actions can be played for each item"))
+		}
+		if frame.Copies > 1 || frame.Matrix != nil {
+			errs = append(errs, field.Invalid(forEachPath, frame.Name, "forEach, matrix and copies are mutually exclusive"))
+		}
+		if _, err := frame.ForEachFrame(); err != nil {
+			errs = append(errs, field.Invalid(forEachPath, frame.ForEach, err.Error()))
+		}
+	}
+	outputNames := make(map[string]bool)
+	for i, output := range frame.Outputs {
+		outputPath := path.Child("outputs").Index(i).Child("name")
+		if frame.Action == nil {
+			errs = append(errs, field.Invalid(outputPath, output.Name, "only actions can publish outputs"))
+		}
+		if !outputName.MatchString(output.Name) {
+			errs = append(errs, field.Invalid(outputPath, output.Name, "must contain only letters, digits, underscores and dashes"))
+		}
+		if outputNames[output.Name] {
+			errs = append(errs, field.Duplicate(outputPath, output.Name))
+		}
+		outputNames[output.Name] = true
+	}
+	if frame.Retry != nil {
+		retryPath := path.Child("retry")
+		if frame.Action == nil {
+			errs = append(errs, field.Invalid(retryPath, frame.Name, "only actions can be retried"))
+		}
+		if frame.Retry.Limit < 0 {
+			errs = append(errs, field.Invalid(retryPath.Child("limit"), frame.Retry.Limit, "must be non-negative"))
+		}
+		if frame.Retry.RetryOn != nil {
+			if _, err := regexp.Compile(frame.Retry.RetryOn.TerminationMessage); err != nil {
+				errs = append(errs, field.Invalid(retryPath.Child("retryOn", "terminationMessage"), frame.Retry.RetryOn.TerminationMessage, err.Error()))
+			}
+		}
+	}
+	if frame.When != "" {
+		if err := when.Validate(frame.When); err != nil {
+			errs = append(errs, field.Invalid(path.Child("when"), frame.When, err.Error()))
+		}
+	}
+	return errs
+}
+
+// varName matches names of vars which can be used both as environment variables and file names
+var varName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+func validateVars(vars []Var, path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	names := make(map[string]bool)
+	for i, v := range vars {
+		varPath := path.Index(i)
+		if !varName.MatchString(v.Name) {
+			errs = append(errs, field.Invalid(varPath.Child("name"), v.Name, "must start with a letter or an underscore and contain only letters, digits and underscores"))
+		}
+		if names[v.Name] {
+			errs = append(errs, field.Duplicate(varPath.Child("name"), v.Name))
+		}
+		names[v.Name] = true
+
+		if v.ValueFrom == nil {
+			continue
+		}
+		if v.Value != "" {
+			errs = append(errs, field.Invalid(varPath.Child("valueFrom"), v.Name, "value and valueFrom are mutually exclusive"))
+		}
+		sources := 0
+		if v.ValueFrom.ConfigMapKeyRef != nil {
+			sources++
+		}
+		if v.ValueFrom.SecretKeyRef != nil {
+			sources++
+		}
+		if v.ValueFrom.EventField != "" {
+			sources++
+		}
+		if sources != 1 {
+			errs = append(errs, field.Invalid(varPath.Child("valueFrom"), v.Name, "exactly one source of the value is required"))
+		}
+	}
+	return errs
+}
+
+// outputName matches names of outputs which can be referenced by other frames
+var outputName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// matrixAxisName matches axis names which can be turned into environment variables
+var matrixAxisName = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
+
+func validateMatrix(frame Frame, path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if frame.Action == nil {
+		errs = append(errs, field.Invalid(path, frame.Name, "only actions can be played as a matrix"))
+	}
+	if frame.Copies > 1 {
+		errs = append(errs, field.Invalid(path, frame.Name, "matrix and copies are mutually exclusive"))
+	}
+	for axis, values := range frame.Matrix.Axes {
+		if !matrixAxisName.MatchString(axis) {
+			errs = append(errs, field.Invalid(path.Child("axes").Key(axis), axis, "must start with a letter and contain only letters, digits and underscores"))
+		}
+		if len(values) == 0 {
+			errs = append(errs, field.Required(path.Child("axes").Key(axis), "at least one value is required"))
+		}
+	}
+	for i, include := range frame.Matrix.Include {
+		if len(include) == 0 {
+			errs = append(errs, field.Required(path.Child("include").Index(i), "at least one value is required"))
+		}
+		for axis := range include {
+			if !matrixAxisName.MatchString(axis) {
+				errs = append(errs, field.Invalid(path.Child("include").Index(i).Key(axis), axis, "must start with a letter and contain only letters, digits and underscores"))
+			}
+		}
+	}
+	for i, exclude := range frame.Matrix.Exclude {
+		for axis := range exclude {
+			if _, ok := frame.Matrix.Axes[axis]; !ok {
+				errs = append(errs, field.NotFound(path.Child("exclude").Index(i).Key(axis), axis))
+			}
+		}
+	}
+	return errs
+}
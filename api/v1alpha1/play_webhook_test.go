@@ -0,0 +1,320 @@
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func validPlay() *Play {
+	story := "cleanup"
+	return &Play{
+		ObjectMeta: metav1.ObjectMeta{Name: "valid"},
+		Spec: PlaySpec{
+			Screenplays: []Screenplay{{
+				Name: "main",
+				Scenes: []Scene{{
+					Name: "build",
+					Frames: []Frame{{
+						Name:   "compile",
+						Action: &Action{},
+						Retry: &RetryPolicy{
+							Limit:   2,
+							RetryOn: &RetryOn{TerminationMessage: "connection (reset|refused)"},
+						},
+					}, {
+						Name:  "cleanup",
+						Story: &story,
+					}},
+				}},
+				Credits: &Credits{
+					Closing: []Frame{{
+						Name:   "notify",
+						Action: &Action{},
+					}},
+				},
+			}, {
+				Name: "cleanup",
+			}},
+		},
+	}
+}
+
+// validatorFunc implements ExpressionValidator with a function
+type validatorFunc func(string) error
+
+func (f validatorFunc) Validate(expression string) error {
+	return f(expression)
+}
+
+// testValidation rejects only the incomplete `when` expression used by the tests
+var testValidation = webhookValidation{
+	when: validatorFunc(func(expression string) error {
+		if expression == `event.branch ==` {
+			return errors.New("incomplete expression")
+		}
+		return nil
+	}),
+}
+
+func TestPlayValidation(t *testing.T) {
+	if err := validPlay().validateCreate(context.TODO(), testValidation); err != nil {
+		t.Errorf("Valid play rejected: %v", err)
+	}
+
+	tests := map[string]func(*Play){
+		"no main screenplay": func(p *Play) {
+			p.Spec.Screenplays[0].Name = "other"
+		},
+		"frame without action or story": func(p *Play) {
+			p.Spec.Screenplays[0].Scenes[0].Frames[0].Action = nil
+		},
+		"frame with action and story": func(p *Play) {
+			p.Spec.Screenplays[0].Scenes[0].Frames[1].Action = &Action{}
+		},
+		"credits frame without action or story": func(p *Play) {
+			p.Spec.Screenplays[0].Credits.Closing[0].Action = nil
+		},
+		"frame with invalid when expression": func(p *Play) {
+			p.Spec.Screenplays[0].Scenes[0].Frames[0].When = `event.branch ==`
+		},
+		"dependencies outside of a DAG": func(p *Play) {
+			p.Spec.Screenplays[0].Scenes[0].Frames[1].DependsOn = []string{"compile"}
+		},
+		"dependency on missing frame": func(p *Play) {
+			p.Spec.Screenplays[0].DAG = true
+			p.Spec.Screenplays[0].Scenes[0].Frames[1].DependsOn = []string{"missing"}
+		},
+		"dependency cycle": func(p *Play) {
+			p.Spec.Screenplays[0].DAG = true
+			p.Spec.Screenplays[0].Scenes[0].Frames[0].DependsOn = []string{"cleanup"}
+			p.Spec.Screenplays[0].Scenes[0].Frames[1].DependsOn = []string{"compile"}
+		},
+		"duplicate frame names": func(p *Play) {
+			p.Spec.Screenplays[0].Scenes[0].Frames[1].Name = "compile"
+		},
+		"retried story": func(p *Play) {
+			p.Spec.Screenplays[0].Scenes[0].Frames[1].Retry = &RetryPolicy{Limit: 1}
+		},
+		"negative retry limit": func(p *Play) {
+			p.Spec.Screenplays[0].Scenes[0].Frames[0].Retry.Limit = -1
+		},
+		"invalid retry termination message": func(p *Play) {
+			p.Spec.Screenplays[0].Scenes[0].Frames[0].Retry.RetryOn.TerminationMessage = "(unclosed"
+		},
+		"matrix of a story": func(p *Play) {
+			p.Spec.Screenplays[0].Scenes[0].Frames[1].Matrix = &Matrix{Axes: map[string][]string{"os": {"alpine"}}}
+		},
+		"matrix with copies": func(p *Play) {
+			p.Spec.Screenplays[0].Scenes[0].Frames[0].Copies = 2
+			p.Spec.Screenplays[0].Scenes[0].Frames[0].Matrix = &Matrix{Axes: map[string][]string{"os": {"alpine"}}}
+		},
+		"matrix axis without values": func(p *Play) {
+			p.Spec.Screenplays[0].Scenes[0].Frames[0].Matrix = &Matrix{Axes: map[string][]string{"os": {}}}
+		},
+		"invalid matrix axis name": func(p *Play) {
+			p.Spec.Screenplays[0].Scenes[0].Frames[0].Matrix = &Matrix{Axes: map[string][]string{"go-version": {"1.15"}}}
+		},
+		"matrix excluding unknown axis": func(p *Play) {
+			p.Spec.Screenplays[0].Scenes[0].Frames[0].Matrix = &Matrix{
+				Axes:    map[string][]string{"os": {"alpine"}},
+				Exclude: []map[string]string{{"arch": "arm64"}},
+			}
+		},
+		"forEach of a story": func(p *Play) {
+			p.Spec.Screenplays[0].Scenes[0].Frames[1].ForEach = "frames.compile.output"
+		},
+		"forEach with invalid reference": func(p *Play) {
+			p.Spec.Screenplays[0].Scenes[0].Frames[0].ForEach = "output.compile"
+		},
+		"forEach over missing frame": func(p *Play) {
+			p.Spec.Screenplays[0].Scenes[0].Frames[0].ForEach = `frames["missing"].output`
+		},
+		"outputs of a story": func(p *Play) {
+			p.Spec.Screenplays[0].Scenes[0].Frames[1].Outputs = []Output{{Name: "digest"}}
+		},
+		"duplicate outputs": func(p *Play) {
+			p.Spec.Screenplays[0].Scenes[0].Frames[0].Outputs = []Output{{Name: "digest"}, {Name: "digest"}}
+		},
+		"invalid output name": func(p *Play) {
+			p.Spec.Screenplays[0].Scenes[0].Frames[0].Outputs = []Output{{Name: "image.digest"}}
+		},
+		"invalid var name": func(p *Play) {
+			p.Spec.Vars = []Var{{Name: "image-tag", Value: "v1"}}
+		},
+		"duplicate vars": func(p *Play) {
+			p.Spec.Screenplays[0].Vars = []Var{{Name: "TAG", Value: "v1"}, {Name: "TAG", Value: "v2"}}
+		},
+		"var with value and valueFrom": func(p *Play) {
+			p.Spec.Vars = []Var{{Name: "BRANCH", Value: "main", ValueFrom: &VarSource{EventField: "branch"}}}
+		},
+		"var with multiple sources": func(p *Play) {
+			p.Spec.Vars = []Var{{Name: "TOKEN", ValueFrom: &VarSource{
+				EventField:   "token",
+				SecretKeyRef: &corev1.SecretKeySelector{Key: "token"},
+			}}}
+		},
+		"volume claim template without name": func(p *Play) {
+			p.Spec.VolumeClaimTemplates = []corev1.PersistentVolumeClaim{{}}
+		},
+		"duplicate volume claim templates": func(p *Play) {
+			p.Spec.VolumeClaimTemplates = []corev1.PersistentVolumeClaim{{
+				ObjectMeta: metav1.ObjectMeta{Name: "workspace"},
+			}, {
+				ObjectMeta: metav1.ObjectMeta{Name: "workspace"},
+			}}
+		},
+		"resource provisioned by multiple screenplays": func(p *Play) {
+			cache := runtime.RawExtension{Raw: []byte(`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "cache"}}`)}
+			p.Spec.Screenplays[0].Provision.Resources = []runtime.RawExtension{cache}
+			p.Spec.Screenplays[1].Provision.Resources = []runtime.RawExtension{cache}
+		},
+		"provisioned resource conflicting with volume claim template": func(p *Play) {
+			p.Spec.VolumeClaimTemplates = []corev1.PersistentVolumeClaim{{
+				ObjectMeta: metav1.ObjectMeta{Name: "workspace"},
+			}}
+			p.Spec.Screenplays[1].Provision.Resources = []runtime.RawExtension{{
+				Raw: []byte(`{"apiVersion": "v1", "kind": "PersistentVolumeClaim", "metadata": {"name": "workspace"}}`),
+			}}
+		},
+		"screenplay referenced by multiple stories": func(p *Play) {
+			story := "cleanup"
+			p.Spec.Screenplays[0].Credits.Closing = append(p.Spec.Screenplays[0].Credits.Closing, Frame{
+				Name:  "final-cleanup",
+				Story: &story,
+			})
+		},
+		"missing story": func(p *Play) {
+			p.Spec.Screenplays[1].Name = "other"
+		},
+	}
+	for name, mutate := range tests {
+		play := validPlay()
+		mutate(play)
+		if err := play.validateCreate(context.TODO(), testValidation); err == nil {
+			t.Errorf("Expected play with %s to be rejected", name)
+		}
+	}
+}
+
+func TestPlayForEachValidation(t *testing.T) {
+	play := validPlay()
+	play.Spec.Screenplays[0].Scenes = append(play.Spec.Screenplays[0].Scenes, Scene{
+		Name: "test",
+		Frames: []Frame{{
+			Name:    "test",
+			Action:  &Action{},
+			ForEach: "frames.compile.output",
+		}},
+	})
+	if err := play.validateCreate(context.TODO(), testValidation); err != nil {
+		t.Errorf("Valid play rejected: %v", err)
+	}
+}
+
+func TestPlayCancelValidation(t *testing.T) {
+	old := validPlay()
+	old.Spec.Cancel = true
+	if err := validPlay().validateUpdate(context.TODO(), testValidation, old); err == nil {
+		t.Errorf("Cancelled play shouldn't be resumed")
+	}
+	if err := old.DeepCopy().validateUpdate(context.TODO(), testValidation, validPlay()); err != nil {
+		t.Errorf("Play should be cancellable: %v", err)
+	}
+}
+
+func TestMovieValidation(t *testing.T) {
+	movie := &Movie{
+		Spec: MovieSpec{
+			Template: PlayTemplate{
+				Spec: validPlay().Spec,
+			},
+		},
+	}
+	if err := movie.validateCreate(context.TODO(), testValidation); err != nil {
+		t.Errorf("Valid movie rejected: %v", err)
+	}
+
+	movie.Spec.Template.Spec.Screenplays[0].Name = "other"
+	if err := movie.validateCreate(context.TODO(), testValidation); err == nil {
+		t.Errorf("Expected movie without main screenplay to be rejected")
+	}
+}
+
+func TestPlayDefault(t *testing.T) {
+	limit := int32(3)
+	play := validPlay()
+	play.Spec.Screenplays[0].Scenes[0].Frames[0].Action.BackoffLimit = &limit
+	play.Spec.Screenplays[0].Scenes[0].Frames[0].Action.Template.Spec.RestartPolicy = corev1.RestartPolicyOnFailure
+	play.Default()
+
+	compile := play.Spec.Screenplays[0].Scenes[0].Frames[0].Action
+	if *compile.BackoffLimit != limit || compile.Template.Spec.RestartPolicy != corev1.RestartPolicyOnFailure {
+		t.Errorf("Defaulting overrode configured values")
+	}
+	notify := play.Spec.Screenplays[0].Credits.Closing[0].Action
+	if notify.BackoffLimit == nil || *notify.BackoffLimit != 0 {
+		t.Errorf("Expected backoff limit to default to 0, got %v", notify.BackoffLimit)
+	}
+	if notify.Template.Spec.RestartPolicy != corev1.RestartPolicyNever {
+		t.Errorf("Expected restart policy to default to Never, got %s", notify.Template.Spec.RestartPolicy)
+	}
+}
+
+func TestEventMovieValidation(t *testing.T) {
+	s := runtime.NewScheme()
+	AddToScheme(s)
+	movie := &Movie{ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"}}
+	reader := fake.NewFakeClientWithScheme(s, movie)
+
+	event := &Event{
+		ObjectMeta: metav1.ObjectMeta{Name: "push", Namespace: "default"},
+		Spec:       EventSpec{Movie: "hello"},
+	}
+	if errs := validateEventMovie(context.TODO(), reader, event); len(errs) != 0 {
+		t.Errorf("Event of existing movie rejected: %v", errs)
+	}
+
+	event.Spec.Movie = "missing"
+	if errs := validateEventMovie(context.TODO(), reader, event); len(errs) == 0 {
+		t.Errorf("Expected event of missing movie to be rejected")
+	}
+}
+
+func TestValidatingHandler(t *testing.T) {
+	s := runtime.NewScheme()
+	AddToScheme(s)
+	decoder, err := admission.NewDecoder(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := &validatingHandler{validator: &Play{}, validation: testValidation}
+	handler.InjectDecoder(decoder)
+
+	play := validPlay()
+	play.Spec.Screenplays[0].Scenes[0].Frames[0].When = `event.branch ==`
+	for _, tc := range []struct {
+		play    *Play
+		allowed bool
+	}{{validPlay(), true}, {play, false}} {
+		raw, err := json.Marshal(tc.play)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response := handler.Handle(context.TODO(), admission.Request{AdmissionRequest: admissionv1beta1.AdmissionRequest{
+			Operation: admissionv1beta1.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+		}})
+		if response.Allowed != tc.allowed {
+			t.Errorf("Want play allowed %t, got %t: %v", tc.allowed, response.Allowed, response.Result)
+		}
+	}
+}
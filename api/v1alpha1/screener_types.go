@@ -40,10 +40,52 @@ type ScreenerSpec struct {
 type ScreenerStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
+
+	// Phase of the Screener watcher
+	// +optional
+	Phase ScreenerPhaseType `json:"phase,omitempty"`
+
+	// A human readable message indicating details about the phase of the watcher.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// The generation of the Screener observed by the running watcher.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Represents time when the watcher was last started.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// Represents time when the last Event was created by the watcher.
+	// +optional
+	LastEventTime *metav1.Time `json:"lastEventTime,omitempty"`
+
+	// State persisted by the screener implementation between restarts of the watcher,
+	// e.g. last seen revisions of a repository.
+	// +optional
+	State map[string]string `json:"state,omitempty"`
 }
 
+// ScreenerPhaseType defines the phase of a Screener watcher
+type ScreenerPhaseType string
+
+// These are valid phases of a Screener watcher.
+const (
+	// ScreenerPhaseRunning means the watcher is watching for new Events.
+	ScreenerPhaseRunning ScreenerPhaseType = "Running"
+	// ScreenerPhaseFailed means the watcher stopped because of an error and will be restarted.
+	ScreenerPhaseFailed ScreenerPhaseType = "Failed"
+	// ScreenerPhaseUnsupported means there is no implementation registered for the type of the Screener.
+	ScreenerPhaseUnsupported ScreenerPhaseType = "Unsupported"
+)
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Type",type=string,JSONPath=`.spec.type`
+// +kubebuilder:printcolumn:name="Movie",type=string,JSONPath=`.spec.movie`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Last Event",type=date,JSONPath=`.status.lastEventTime`
 
 // Screener is the Schema for the screeners API
 type Screener struct {
@@ -2,8 +2,12 @@ package v1alpha1
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 
 	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -13,6 +17,13 @@ type Screenplay struct {
 	Provision `json:"provision,omitempty"`
 	Scenes    []Scene  `json:"scenes,omitempty"`
 	Credits   *Credits `json:"credits,omitempty"`
+	// Vars are available to all the frames of the screenplay, overriding vars of the Play with the same name
+	// +optional
+	Vars []Var `json:"vars,omitempty"`
+	// DAG plays frames of the scenes as soon as all the frames they depend on succeed,
+	// instead of playing scenes one after another.
+	// +optional
+	DAG bool `json:"dag,omitempty"`
 }
 
 // Credits describe actions that need to be run at the start or at the end of a screenplay.
@@ -27,6 +38,31 @@ type Credits struct {
 	Closing []Frame `json:"closing,omitempty"`
 }
 
+// Var is a variable which is available to frames as an environment variable and as a file under /kuberik/vars
+type Var struct {
+	// Name of the variable. Needs to be a valid name of an environment variable.
+	Name string `json:"name"`
+	// Value of the variable
+	// +optional
+	Value string `json:"value,omitempty"`
+	// ValueFrom selects the value of the variable from another source
+	// +optional
+	ValueFrom *VarSource `json:"valueFrom,omitempty"`
+}
+
+// VarSource selects the value of a variable. Only one of its fields may be set.
+type VarSource struct {
+	// Selects a key of a ConfigMap in the namespace of the Play
+	// +optional
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+	// Selects a key of a Secret in the namespace of the Play
+	// +optional
+	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+	// Selects a key of data of the Event which started the Play
+	// +optional
+	EventField string `json:"eventField,omitempty"`
+}
+
 type Provision struct {
 	Resources []runtime.RawExtension `json:"resources,omitempty"`
 }
@@ -41,43 +77,352 @@ func (s *Screenplay) Scene(name string) (*Scene, error) {
 	return &Scene{}, fmt.Errorf("Scene not found")
 }
 
+// ValidateDependencies checks that frames of the screenplay depend only on existing frames
+// of its scenes and that the dependencies don't form a cycle
+func (s *Screenplay) ValidateDependencies() error {
+	dependencies := make(map[string][]string)
+	for _, scene := range s.Scenes {
+		for _, frame := range scene.Frames {
+			if len(frame.DependsOn) > 0 && !s.DAG {
+				return fmt.Errorf("frame '%s' depends on other frames, but screenplay '%s' isn't played as a DAG", frame.Name, s.Name)
+			}
+			if _, ok := dependencies[frame.Name]; ok && s.DAG {
+				return fmt.Errorf("frame name '%s' isn't unique in screenplay '%s'", frame.Name, s.Name)
+			}
+			dependencies[frame.Name] = frame.DependsOn
+		}
+	}
+	if s.Credits != nil {
+		for _, frame := range append(append([]Frame{}, s.Credits.Opening...), s.Credits.Closing...) {
+			if len(frame.DependsOn) > 0 {
+				return fmt.Errorf("credits frame '%s' can't depend on other frames", frame.Name)
+			}
+			if frame.ForEach != "" {
+				return fmt.Errorf("credits frame '%s' can't iterate over outputs of other frames", frame.Name)
+			}
+		}
+	}
+
+	for _, scene := range s.Scenes {
+		for _, frame := range scene.Frames {
+			for _, dependency := range frame.DependsOn {
+				if _, ok := dependencies[dependency]; !ok {
+					return fmt.Errorf("frame '%s' depends on frame '%s' which doesn't exist", frame.Name, dependency)
+				}
+			}
+			if frame.ForEach != "" {
+				if err := s.validateForEach(frame); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	marks := make(map[string]int)
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch marks[name] {
+		case visiting:
+			return fmt.Errorf("dependencies of frames form a cycle: %s", strings.Join(append(path, name), " -> "))
+		case visited:
+			return nil
+		}
+		marks[name] = visiting
+		for _, dependency := range dependencies[name] {
+			if err := visit(dependency, append(path, name)); err != nil {
+				return err
+			}
+		}
+		marks[name] = visited
+		return nil
+	}
+	for _, scene := range s.Scenes {
+		for _, frame := range scene.Frames {
+			if err := visit(frame.Name, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// forEachReference matches references to outputs of frames, e.g. frames.discover.output or frames["discover-services"].output
+var forEachReference = regexp.MustCompile(`^frames(?:\.([a-zA-Z_][a-zA-Z0-9_]*)|\["([^"]+)"\])\.output$`)
+
+// ForEachFrame returns name of the frame whose output the frame iterates over
+func (f *Frame) ForEachFrame() (string, error) {
+	match := forEachReference.FindStringSubmatch(f.ForEach)
+	if match == nil {
+		return "", fmt.Errorf("forEach of frame '%s' needs to reference output of a frame like frames.name.output, got '%s'", f.Name, f.ForEach)
+	}
+	if match[1] != "" {
+		return match[1], nil
+	}
+	return match[2], nil
+}
+
+// validateForEach checks that a frame iterates over output of another frame of the screenplay
+// which is played as a single frame
+func (s *Screenplay) validateForEach(frame Frame) error {
+	name, err := frame.ForEachFrame()
+	if err != nil {
+		return err
+	}
+	if name == frame.Name {
+		return fmt.Errorf("frame '%s' can't iterate over its own output", frame.Name)
+	}
+	for _, scene := range s.Scenes {
+		for _, producer := range scene.Frames {
+			if producer.Name != name {
+				continue
+			}
+			if producer.Copies > 1 || producer.Matrix != nil || producer.ForEach != "" {
+				return fmt.Errorf("frame '%s' iterates over output of frame '%s' which is played in multiple copies", frame.Name, name)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("frame '%s' iterates over output of frame '%s' which doesn't exist", frame.Name, name)
+}
+
 // Scene describes a collection of frames that need to be executed in parallel
 type Scene struct {
 	Name   string  `json:"name"`
 	Frames []Frame `json:"frames"`
+	// FailFast cancels frames of the scene which are still running once any of its frames fails
+	// +optional
+	FailFast bool `json:"failFast,omitempty"`
+	// Timeout limits the duration of the scene, starting when its first frame starts.
+	// Frames of the scene which don't finish in time fail.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
 }
 
 // Frame describes either an action or story that needs to be executed
 type Frame struct {
-	ID     string  `json:"id,omitempty"`
-	Name   string  `json:"name,omitempty"`
-	Copies int     `json:"copies,omitempty"`
-	Action *Action `json:"action,omitempty"`
-	Story  *string `json:"story,omitempty"`
+	ID     string `json:"id,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Copies int    `json:"copies,omitempty"`
+	// Matrix plays a copy of the frame for every combination of values of its axes.
+	// It can't be used together with copies.
+	// +optional
+	Matrix *Matrix `json:"matrix,omitempty"`
+	// ForEach plays a copy of the frame for every item of a JSON list published by another frame
+	// of the same screenplay as its termination message, referenced as `frames.discover.output`.
+	// Copies are created once the referenced frame succeeds. It can't be used together with copies or matrix.
+	// +optional
+	ForEach string `json:"forEach,omitempty"`
+	// Outputs are values which the frame publishes in the termination message of its containers.
+	// Other frames of the same screenplay can reference them as `$(frames.build.outputs.digest)`.
+	// +optional
+	Outputs []Output `json:"outputs,omitempty"`
+	Action  *Action  `json:"action,omitempty"`
+	// Story references another Screenplay of the same Play by its name.
+	// Referenced Screenplay is played as a single frame, including its credits.
+	Story *string `json:"story,omitempty"`
+	// When is an expression which decides if the frame is played. Frames with expressions
+	// evaluating to false are skipped. Expressions can reference Event data (`event.branch`),
+	// annotations of the Play (`annotations["example.com/key"]`) and statuses of
+	// frames from the same screenplay (`frames.test.status == "failed"`).
+	// +optional
+	When string `json:"when,omitempty"`
+	// DependsOn lists names of frames from scenes of the same screenplay which need to succeed
+	// before the frame is played. It can only be used in screenplays played as a DAG.
+	// +optional
+	DependsOn []string `json:"dependsOn,omitempty"`
+	// AllowFailure records failure of the frame without failing the Play
+	// +optional
+	AllowFailure bool `json:"allowFailure,omitempty"`
+	// Timeout limits the duration of the frame. Frame which doesn't finish in time fails.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+	// Retry plays the action of the frame again with a new Job when it fails.
+	// +optional
+	Retry *RetryPolicy `json:"retry,omitempty"`
+}
+
+// Matrix describes combinations of values for which copies of a frame are played
+type Matrix struct {
+	// Axes map names of the axes to their values. Every combination of the values is played.
+	// +optional
+	Axes map[string][]string `json:"axes,omitempty"`
+	// Include lists additional combinations which are played
+	// +optional
+	Include []map[string]string `json:"include,omitempty"`
+	// Exclude lists combinations which aren't played. Combinations matching all the values
+	// of any of the listed entries are excluded.
+	// +optional
+	Exclude []map[string]string `json:"exclude,omitempty"`
+}
+
+// Output describes a value published by a frame
+type Output struct {
+	// Name of the output
+	Name string `json:"name"`
 }
 
-// FrameStatus represents end result of a frame
-type FrameStatus int
+// RetryPolicy describes when and how often a failed frame is retried
+type RetryPolicy struct {
+	// Limit is the maximum number of retries
+	Limit int32 `json:"limit"`
+	// Backoff is the delay before each retry. Frames are retried immediately if it's not set.
+	// +optional
+	Backoff *Backoff `json:"backoff,omitempty"`
+	// RetryOn limits retries to failures matching the conditions. Any failure is retried if it's not set.
+	// +optional
+	RetryOn *RetryOn `json:"retryOn,omitempty"`
+}
+
+// Backoff describes an exponentially growing delay
+type Backoff struct {
+	// Base is the delay before the first retry. It doubles with every following retry.
+	Base metav1.Duration `json:"base"`
+	// Max limits the delay
+	// +optional
+	Max *metav1.Duration `json:"max,omitempty"`
+}
+
+// RetryOn describes failures which are retried. Failure matching any of the conditions is retried.
+type RetryOn struct {
+	// ExitCodes of containers which are retried
+	// +optional
+	ExitCodes []int32 `json:"exitCodes,omitempty"`
+	// TerminationMessage is a regular expression matching termination messages which are retried
+	// +optional
+	TerminationMessage string `json:"terminationMessage,omitempty"`
+}
+
+// FrameStatus describes the execution of a frame
+type FrameStatus struct {
+	// Name of the frame
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Scene of the screenplay which the frame belongs to. Empty for credits.
+	// +optional
+	Scene string `json:"scene,omitempty"`
+
+	// Screenplay which the frame belongs to
+	// +optional
+	Screenplay string `json:"screenplay,omitempty"`
+
+	// State of the frame
+	State FrameState `json:"state"`
+
+	// FailureAllowed is set for frames whose failure doesn't fail the Play
+	// +optional
+	FailureAllowed bool `json:"failureAllowed,omitempty"`
+
+	// A brief CamelCase reason for the state of the frame.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// A human readable message indicating details about the state of the frame.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Represents time when the frame started its execution.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// Represents time when the frame finished its execution.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Number of pods started to play the frame.
+	// +optional
+	Attempts int32 `json:"attempts,omitempty"`
+
+	// JobRef is referencing the Job created for the action of the frame
+	// +optional
+	JobRef *corev1.ObjectReference `json:"jobRef,omitempty"`
+
+	// Names of the pods started for the action of the frame
+	// +optional
+	Pods []string `json:"pods,omitempty"`
+
+	// Exit codes of terminated containers of the last pod by container name
+	// +optional
+	ExitCodes map[string]int32 `json:"exitCodes,omitempty"`
+
+	// Termination message of the last terminated container of the last pod
+	// +optional
+	TerminationMessage string `json:"terminationMessage,omitempty"`
+
+	// Retries describe failed attempts to play the frame which were retried
+	// +optional
+	Retries []FrameAttempt `json:"retries,omitempty"`
+
+	// RetryAfter is the time after which the frame is retried
+	// +optional
+	RetryAfter *metav1.Time `json:"retryAfter,omitempty"`
+
+	// Outputs published by the frame by their names
+	// +optional
+	Outputs map[string]string `json:"outputs,omitempty"`
+}
+
+// FrameAttempt describes a failed attempt to play a frame
+type FrameAttempt struct {
+	// JobRef is referencing the Job created for the attempt
+	// +optional
+	JobRef *corev1.ObjectReference `json:"jobRef,omitempty"`
+
+	// Represents time when the attempt started.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// Represents time when the attempt failed.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Exit codes of terminated containers of the last pod by container name
+	// +optional
+	ExitCodes map[string]int32 `json:"exitCodes,omitempty"`
+
+	// Termination message of the last terminated container of the last pod
+	// +optional
+	TerminationMessage string `json:"terminationMessage,omitempty"`
+}
+
+// FrameState defines the state of a frame
+type FrameState string
+
+// These are valid states of a frame.
+const (
+	// FrameStatePending means the frame hasn't started yet.
+	FrameStatePending FrameState = "Pending"
+	// FrameStateRunning means the frame is executing.
+	FrameStateRunning FrameState = "Running"
+	// FrameStateSucceeded means the frame ended successfully.
+	FrameStateSucceeded FrameState = "Succeeded"
+	// FrameStateFailed means the frame finished with an error.
+	FrameStateFailed FrameState = "Failed"
+	// FrameStateSkipped means the frame won't be played.
+	FrameStateSkipped FrameState = "Skipped"
+	// FrameStateCancelled means the frame was stopped before it finished.
+	FrameStateCancelled FrameState = "Cancelled"
+)
 
+// Reasons of frame states.
 const (
-	// FrameStatusSuccessful indicates that frame ended sucessfully
-	FrameStatusSuccessful FrameStatus = iota
-	// FrameStatusFailed indicates that frame finished with an error
-	FrameStatusFailed
-	// FrameStatusRunning indicates that frame is running
-	FrameStatusRunning
+	// FrameReasonTimeout means the frame didn't finish before its timeout, or the timeout of its scene or Play, expired.
+	FrameReasonTimeout = "Timeout"
+	// FrameReasonMissingOutputs means the frame finished without publishing all of its outputs.
+	FrameReasonMissingOutputs = "MissingOutputs"
 )
 
-func (fr FrameStatus) String() string {
-	switch fr {
-	case FrameStatusSuccessful:
-		return "success"
-	case FrameStatusFailed:
-		return "failed"
-	case FrameStatusRunning:
-		return "running"
+// Finished checks if a frame in the state ended its execution
+func (fs FrameState) Finished() bool {
+	switch fs {
+	case FrameStateSucceeded, FrameStateFailed, FrameStateSkipped, FrameStateCancelled:
+		return true
 	}
-	panic("Frame result not defined")
+	return false
 }
 
 // +kubebuilder:object:generate=false
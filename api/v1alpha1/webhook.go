@@ -0,0 +1,101 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"net/http"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// ExpressionValidator validates expressions without evaluating them
+// +kubebuilder:object:generate=false
+type ExpressionValidator interface {
+	Validate(expression string) error
+}
+
+// webhookValidation holds dependencies of validating webhooks which are injected when
+// the webhooks are set up, so that the API types don't depend on their implementations
+type webhookValidation struct {
+	// reader looks up objects referenced by the validated ones
+	reader client.Reader
+	// when validates `when` expressions of frames
+	when ExpressionValidator
+}
+
+// webhookValidator is implemented by the types validated with injected dependencies
+type webhookValidator interface {
+	runtime.Object
+	validateCreate(ctx context.Context, v webhookValidation) error
+	validateUpdate(ctx context.Context, v webhookValidation, old runtime.Object) error
+}
+
+// validatingHandler validates objects of admission requests. Unlike the handler
+// registered by controller-runtime, it passes dependencies to the validated objects.
+type validatingHandler struct {
+	validator  webhookValidator
+	validation webhookValidation
+	decoder    *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &validatingHandler{}
+
+// InjectDecoder injects the decoder into a validatingHandler
+func (h *validatingHandler) InjectDecoder(d *admission.Decoder) error {
+	h.decoder = d
+	return nil
+}
+
+// Handle handles admission requests
+func (h *validatingHandler) Handle(ctx context.Context, req admission.Request) admission.Response {
+	obj := h.validator.DeepCopyObject().(webhookValidator)
+	switch req.Operation {
+	case admissionv1beta1.Create:
+		if err := h.decoder.Decode(req, obj); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if err := obj.validateCreate(ctx, h.validation); err != nil {
+			return admission.Denied(err.Error())
+		}
+	case admissionv1beta1.Update:
+		oldObj := obj.DeepCopyObject()
+		if err := h.decoder.DecodeRaw(req.Object, obj); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if err := h.decoder.DecodeRaw(req.OldObject, oldObj); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if err := obj.validateUpdate(ctx, h.validation, oldObj); err != nil {
+			return admission.Denied(err.Error())
+		}
+	}
+	return admission.Allowed("")
+}
+
+// registerValidatingWebhook serves the validating webhook of a type on the path
+// referenced by its kubebuilder marker
+func registerValidatingWebhook(mgr ctrl.Manager, path string, validator webhookValidator, validation webhookValidation) {
+	mgr.GetWebhookServer().Register(path, &webhook.Admission{
+		Handler: &validatingHandler{validator: validator, validation: validation},
+	})
+}
@@ -21,17 +21,26 @@ import (
 	"fmt"
 
 	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	ref "k8s.io/client-go/tools/reference"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
+	"github.com/kuberik/engine/pkg/engine"
 	"github.com/kuberik/engine/pkg/kubeutils"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// PlayLabelEvent is name of a label which stores name of the Event that started a Play
+	PlayLabelEvent = "core.kuberik.io/event"
 )
 
 // EventReconciler reconciles a Event object
@@ -45,12 +54,12 @@ type EventReconciler struct {
 // +kubebuilder:rbac:groups=core.kuberik.io,resources=events/status,verbs=get;update;patch
 
 func (r *EventReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
-	_ = context.Background()
-	_ = r.Log.WithValues("event", req.NamespacedName)
+	ctx := context.Background()
+	log := r.Log.WithValues("event", req.NamespacedName)
 
 	// Fetch the Event instance
 	instance := &corev1alpha1.Event{}
-	err := r.Client.Get(context.TODO(), req.NamespacedName, instance)
+	err := r.Client.Get(ctx, req.NamespacedName, instance)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			// Request object not found, could have been deleted after reconcile request.
@@ -62,25 +71,98 @@ func (r *EventReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		return reconcile.Result{}, err
 	}
 
+	if instance.Finished() {
+		return reconcile.Result{}, nil
+	}
+
+	if instance.Status.Phase == "" {
+		instance.Status.Phase = corev1alpha1.EventPhasePending
+		instance.Status.ObservedGeneration = instance.Generation
+		if err := r.Client.Status().Update(ctx, instance); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	status := instance.Status.DeepCopy()
+	err = r.dispatch(ctx, instance)
+	if err != nil {
+		log.Error(err, "failed to dispatch event")
+	}
+	instance.Status.ObservedGeneration = instance.Generation
+	if !equality.Semantic.DeepEqual(status, &instance.Status) {
+		if err := r.Client.Status().Update(ctx, instance); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+	return reconcile.Result{}, err
+}
+
+// dispatch creates the Play of an Event and records the outcome in the status of the Event.
+// Returned error means the dispatching should be retried.
+func (r *EventReconciler) dispatch(ctx context.Context, event *corev1alpha1.Event) error {
 	movie := &corev1alpha1.Movie{}
-	err = r.Client.Get(context.TODO(), types.NamespacedName{
-		Name:      instance.Spec.Movie,
-		Namespace: instance.Namespace,
+	err := r.Client.Get(ctx, types.NamespacedName{
+		Name:      event.Spec.Movie,
+		Namespace: event.Namespace,
 	}, movie)
+	if errors.IsNotFound(err) {
+		setEventPhase(event, corev1alpha1.EventPhaseRejected, corev1.ConditionFalse, corev1alpha1.EventReasonMovieNotFound,
+			fmt.Sprintf("Movie '%s' not found", event.Spec.Movie))
+		return nil
+	}
 	if err != nil {
-		// TODO: update status to error
-		// TODO: this should not happen if event validation hook is deployed
-		return reconcile.Result{}, err
+		return err
 	}
 
-	// TODO: test the GeneratePlay method
 	// TODO: test using operator-sdk e2e testing
-	p := generateEventPlay(*movie, *instance)
-	err = r.Client.Create(context.TODO(), &p)
-	if err != nil && !errors.IsAlreadyExists(err) {
-		return reconcile.Result{Requeue: true}, err
+	p := generateEventPlay(*movie, *event)
+	play := &p
+	err = r.Client.Create(ctx, play)
+	if errors.IsAlreadyExists(err) {
+		// Play might have been created by a previous reconciliation which failed to update the status
+		existing := &corev1alpha1.Play{}
+		err = r.Client.Get(ctx, types.NamespacedName{Name: p.Name, Namespace: p.Namespace}, existing)
+		if err == nil && !ownedBy(existing, event) {
+			setEventPhase(event, corev1alpha1.EventPhaseRejected, corev1.ConditionFalse, corev1alpha1.EventReasonPlayConflict,
+				fmt.Sprintf("Play '%s' already exists and wasn't started by this Event", p.Name))
+			return nil
+		}
+		play = existing
+	}
+	if err != nil {
+		setEventPhase(event, corev1alpha1.EventPhaseFailed, corev1.ConditionFalse, corev1alpha1.EventReasonPlayCreateFailed,
+			fmt.Sprintf("Failed to create Play '%s': %s", p.Name, err))
+		return err
+	}
+
+	playRef, err := ref.GetReference(r.Scheme, play)
+	if err != nil {
+		return err
 	}
-	return reconcile.Result{}, nil
+	event.Status.PlayRef = playRef
+	setEventPhase(event, corev1alpha1.EventPhaseDispatched, corev1.ConditionTrue, corev1alpha1.EventReasonPlayCreated,
+		fmt.Sprintf("Play '%s' created", p.Name))
+	return nil
+}
+
+func setEventPhase(event *corev1alpha1.Event, phase corev1alpha1.EventPhaseType, status corev1.ConditionStatus, reason, message string) {
+	event.Status.Phase = phase
+	corev1alpha1.SetCondition(&event.Status.Conditions, corev1alpha1.Condition{
+		Type:    corev1alpha1.EventConditionDispatched,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	})
+}
+
+// ownedBy returns true if the object has an owner reference to the owner
+func ownedBy(object metav1.Object, owner metav1.Object) bool {
+	for _, ref := range object.GetOwnerReferences() {
+		if ref.UID == owner.GetUID() && ref.Name == owner.GetName() {
+			return true
+		}
+	}
+	return false
 }
 
 func (r *EventReconciler) SetupWithManager(mgr ctrl.Manager) error {
@@ -90,12 +172,21 @@ func (r *EventReconciler) SetupWithManager(mgr ctrl.Manager) error {
 }
 
 func generatePlay(movie corev1alpha1.Movie) corev1alpha1.Play {
+	var annotations map[string]string
+	if movie.Spec.Template.Annotations != nil {
+		annotations = make(map[string]string)
+		for k, v := range movie.Spec.Template.Annotations {
+			annotations[k] = v
+		}
+	}
 	return corev1alpha1.Play{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace:       movie.Namespace,
+			Labels:          labels.Merge(movie.Spec.Template.Labels, labels.Set{PlayLabelMovie: movie.Name}),
+			Annotations:     annotations,
 			OwnerReferences: []metav1.OwnerReference{kubeutils.OwnerReference(&movie)},
 		},
-		Spec: movie.Spec.Template.Spec,
+		Spec: *movie.Spec.Template.Spec.DeepCopy(),
 	}
 }
 
@@ -103,19 +194,23 @@ func generateEventPlay(movie corev1alpha1.Movie, event corev1alpha1.Event) corev
 	play := generatePlay(movie)
 	play.OwnerReferences = append(play.OwnerReferences, kubeutils.OwnerReference(&event))
 	play.Name = fmt.Sprintf("%s-%s", movie.Name, event.Name)
+	play.Labels[PlayLabelEvent] = event.Name
 	eventDataConfigMap := corev1.ConfigMap{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "v1",
 			Kind:       "ConfigMap",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name: "event-data",
+			Name: engine.EventDataConfigMapName,
 		},
 		Data: event.Spec.Data,
 	}
-	play.Spec.Screenplays[0].Provision.Resources = append(
-		play.Spec.Screenplays[0].Provision.Resources,
-		runtime.RawExtension{Object: &eventDataConfigMap},
-	)
+	// Data of the Event is provisioned for the whole Play, so it's visible to all the stories
+	if main := play.Screenplay(corev1alpha1.MainScreenplayName); main != nil {
+		main.Provision.Resources = append(
+			main.Provision.Resources,
+			runtime.RawExtension{Object: &eventDataConfigMap},
+		)
+	}
 	return play
 }
@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func newEventReconciler(objs ...runtime.Object) (*EventReconciler, client.Client) {
+	s := scheme.Scheme
+	corev1alpha1.AddToScheme(s)
+	c := fake.NewFakeClientWithScheme(s, objs...)
+	return &EventReconciler{
+		Client: c,
+		Scheme: s,
+		Log:    ctrl.Log.WithName("controllers").WithName("Event"),
+	}, c
+}
+
+func testEvent(movie string) *corev1alpha1.Event {
+	return &corev1alpha1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "push",
+			Namespace: "default",
+			UID:       "event-uid",
+		},
+		Spec: corev1alpha1.EventSpec{
+			Movie: movie,
+			Data:  map[string]string{"sha": "abc"},
+		},
+	}
+}
+
+func reconcileEvent(t *testing.T, r *EventReconciler, c client.Client, event *corev1alpha1.Event) *corev1alpha1.Event {
+	nn := types.NamespacedName{Name: event.Name, Namespace: event.Namespace}
+	if _, err := r.Reconcile(reconcile.Request{NamespacedName: nn}); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+	updated := &corev1alpha1.Event{}
+	if err := c.Get(context.TODO(), nn, updated); err != nil {
+		t.Fatalf("get event: (%v)", err)
+	}
+	return updated
+}
+
+func TestEventDispatched(t *testing.T) {
+	movie := scheduledMovie("hello", metav1.Now().Time)
+	movie.Spec.Schedule = ""
+	event := testEvent(movie.Name)
+	r, c := newEventReconciler(movie, event)
+
+	updated := reconcileEvent(t, r, c, event)
+	if want := corev1alpha1.EventPhaseDispatched; updated.Status.Phase != want {
+		t.Errorf("Expected phase %s, got %s", want, updated.Status.Phase)
+	}
+	if updated.Status.PlayRef == nil || updated.Status.PlayRef.Name != "hello-push" {
+		t.Fatalf("Expected reference to Play hello-push, got %v", updated.Status.PlayRef)
+	}
+	condition := corev1alpha1.FindCondition(updated.Status.Conditions, corev1alpha1.EventConditionDispatched)
+	if condition == nil || condition.Status != corev1.ConditionTrue || condition.Reason != corev1alpha1.EventReasonPlayCreated {
+		t.Errorf("Unexpected dispatched condition: %v", condition)
+	}
+
+	play := &corev1alpha1.Play{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: "hello-push", Namespace: "default"}, play); err != nil {
+		t.Fatalf("Play not created: (%v)", err)
+	}
+	if play.Labels[PlayLabelEvent] != event.Name {
+		t.Errorf("Expected Play to be labeled with the event name, got %v", play.Labels)
+	}
+
+	// Reconciling again doesn't change anything
+	again := reconcileEvent(t, r, c, updated)
+	if again.ResourceVersion != updated.ResourceVersion {
+		t.Errorf("Dispatched event shouldn't be updated")
+	}
+}
+
+func TestEventMovieNotFound(t *testing.T) {
+	event := testEvent("missing")
+	r, c := newEventReconciler(event)
+
+	updated := reconcileEvent(t, r, c, event)
+	if want := corev1alpha1.EventPhaseRejected; updated.Status.Phase != want {
+		t.Errorf("Expected phase %s, got %s", want, updated.Status.Phase)
+	}
+	condition := corev1alpha1.FindCondition(updated.Status.Conditions, corev1alpha1.EventConditionDispatched)
+	if condition == nil || condition.Status != corev1.ConditionFalse || condition.Reason != corev1alpha1.EventReasonMovieNotFound {
+		t.Errorf("Unexpected dispatched condition: %v", condition)
+	}
+
+	// Rejected event is not reconsidered once the Movie is created
+	movie := scheduledMovie("missing", metav1.Now().Time)
+	movie.Spec.Schedule = ""
+	c.Create(context.TODO(), movie)
+	updated = reconcileEvent(t, r, c, updated)
+	if want := corev1alpha1.EventPhaseRejected; updated.Status.Phase != want {
+		t.Errorf("Expected phase %s, got %s", want, updated.Status.Phase)
+	}
+}
+
+func TestEventPlayConflict(t *testing.T) {
+	movie := scheduledMovie("hello", metav1.Now().Time)
+	movie.Spec.Schedule = ""
+	event := testEvent(movie.Name)
+	play := &corev1alpha1.Play{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "hello-push",
+			Namespace: "default",
+		},
+	}
+	r, c := newEventReconciler(movie, event, play)
+
+	updated := reconcileEvent(t, r, c, event)
+	if want := corev1alpha1.EventPhaseRejected; updated.Status.Phase != want {
+		t.Errorf("Expected phase %s, got %s", want, updated.Status.Phase)
+	}
+	if updated.Status.PlayRef != nil {
+		t.Errorf("Expected no Play reference, got %v", updated.Status.PlayRef)
+	}
+}
+
+func TestGenerateEventPlayProvisionsEventDataInMainScreenplay(t *testing.T) {
+	movie := scheduledMovie("hello", metav1.Now().Time)
+	movie.Spec.Template.Spec.Screenplays = []corev1alpha1.Screenplay{{
+		Name: "build",
+	}, {
+		Name: corev1alpha1.MainScreenplayName,
+	}}
+	event := testEvent(movie.Name)
+
+	play := generateEventPlay(*movie, *event)
+	if resources := play.Spec.Screenplays[0].Provision.Resources; len(resources) != 0 {
+		t.Errorf("Expected no resources provisioned by the story, got %v", resources)
+	}
+	resources := play.Spec.Screenplays[1].Provision.Resources
+	if len(resources) != 1 {
+		t.Fatalf("Expected event data to be provisioned by the main screenplay, got %v", resources)
+	}
+	if cm, ok := resources[0].Object.(*corev1.ConfigMap); !ok || cm.Data["sha"] != "abc" {
+		t.Errorf("Expected ConfigMap with event data, got %v", resources[0].Object)
+	}
+	if len(movie.Spec.Template.Spec.Screenplays[1].Provision.Resources) != 0 {
+		t.Errorf("Template of the Movie shouldn't be modified")
+	}
+}
+
+func TestEventPlayAlreadyCreated(t *testing.T) {
+	movie := scheduledMovie("hello", metav1.Now().Time)
+	movie.Spec.Schedule = ""
+	event := testEvent(movie.Name)
+	// Play created by a previous reconciliation which failed to update status of the Event
+	play := &corev1alpha1.Play{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "hello-push",
+			Namespace: "default",
+			UID:       "play-uid",
+			OwnerReferences: []metav1.OwnerReference{{
+				Name: event.Name,
+				UID:  event.UID,
+			}},
+		},
+	}
+	r, c := newEventReconciler(movie, event, play)
+
+	updated := reconcileEvent(t, r, c, event)
+	if want := corev1alpha1.EventPhaseDispatched; updated.Status.Phase != want {
+		t.Errorf("Expected phase %s, got %s", want, updated.Status.Phase)
+	}
+	playRef := updated.Status.PlayRef
+	if playRef == nil || playRef.Name != play.Name || playRef.UID != play.UID {
+		t.Errorf("Expected complete reference to the existing Play, got %v", playRef)
+	}
+}
@@ -18,36 +18,354 @@ package controllers
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/robfig/cron"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	ref "k8s.io/client-go/tools/reference"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
 )
 
+const (
+	// movieHistorySize is the number of most recent Plays recorded in the status of a Movie
+	movieHistorySize = 10
+)
+
+const (
+	// PlayLabelMovie is name of a label which stores name of the Movie that a Play was created from
+	PlayLabelMovie = "core.kuberik.io/movie"
+
+	// PlayAnnotationScheduledAt is name of an annotation which stores time at which a Play was scheduled
+	PlayAnnotationScheduledAt = "core.kuberik.io/scheduled-at"
+)
+
+// Clock knows how to get the current time.
+// It can be used to fake out timing for testing.
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
 // MovieReconciler reconciles a Movie object
 type MovieReconciler struct {
 	client.Client
 	Log    logr.Logger
 	Scheme *runtime.Scheme
+
+	Clock
 }
 
 // +kubebuilder:rbac:groups=core.kuberik.io,resources=movies,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core.kuberik.io,resources=movies/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=core.kuberik.io,resources=plays,verbs=get;list;watch;create;update;patch;delete
 
 func (r *MovieReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
-	_ = context.Background()
-	_ = r.Log.WithValues("movie", req.NamespacedName)
+	ctx := context.Background()
+	log := r.Log.WithValues("movie", req.NamespacedName)
+
+	movie := &corev1alpha1.Movie{}
+	if err := r.Client.Get(ctx, req.NamespacedName, movie); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
 
-	// your logic here
+	plays := &corev1alpha1.PlayList{}
+	if err := r.Client.List(ctx, plays, client.InNamespace(movie.Namespace), client.MatchingLabels{PlayLabelMovie: movie.Name}); err != nil {
+		return ctrl.Result{}, err
+	}
 
-	return ctrl.Result{}, nil
+	status := movie.Status.DeepCopy()
+	var activePlays, scheduledPlays, successfulPlays, failedPlays []*corev1alpha1.Play
+	movie.Status.Active = nil
+	for i := range plays.Items {
+		play := &plays.Items[i]
+		if play.Status.Successful() {
+			successfulPlays = append(successfulPlays, play)
+		} else if play.Status.Finished() {
+			failedPlays = append(failedPlays, play)
+		} else {
+			activePlays = append(activePlays, play)
+			// Concurrency policy applies only to the Plays created by the schedule
+			if _, ok := play.Annotations[PlayAnnotationScheduledAt]; ok {
+				scheduledPlays = append(scheduledPlays, play)
+			}
+			playRef, err := ref.GetReference(r.Scheme, play)
+			if err != nil {
+				log.Error(err, "unable to make reference to active play", "play", play.Name)
+				continue
+			}
+			movie.Status.Active = append(movie.Status.Active, *playRef)
+		}
+
+		scheduledTime, err := playScheduledTime(play)
+		if err != nil {
+			log.Error(err, "unable to parse schedule time for play", "play", play.Name)
+			continue
+		}
+		if scheduledTime != nil && (movie.Status.LastScheduleTime == nil || movie.Status.LastScheduleTime.Time.Before(*scheduledTime)) {
+			movie.Status.LastScheduleTime = &metav1.Time{Time: *scheduledTime}
+		}
+	}
+
+	successfulPlays = r.deleteOldPlays(ctx, log, successfulPlays, movie.Spec.SuccessfulJobsHistoryLimit)
+	failedPlays = r.deleteOldPlays(ctx, log, failedPlays, movie.Spec.FailedJobsHistoryLimit)
+	movie.Status.RetainedSuccessfulPlays = int32(len(successfulPlays))
+	movie.Status.RetainedFailedPlays = int32(len(failedPlays))
+	movie.Status.Running = int32(len(activePlays))
+	updateHistory(movie, plays.Items)
+
+	if !equality.Semantic.DeepEqual(status, &movie.Status) {
+		if err := r.Client.Status().Update(ctx, movie); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if movie.Spec.Schedule == "" {
+		return ctrl.Result{}, nil
+	}
+
+	now := r.Clock.Now()
+	missedRun, nextRun, err := nextSchedule(movie, now)
+	if err != nil {
+		// Retrying won't help until the schedule is fixed
+		log.Error(err, "unable to figure out movie schedule")
+		return ctrl.Result{}, nil
+	}
+
+	scheduledResult := ctrl.Result{RequeueAfter: nextRun.Sub(now)}
+	if missedRun.IsZero() {
+		return scheduledResult, nil
+	}
+
+	log = log.WithValues("current run", missedRun)
+	if d := movie.Spec.StartingDeadlineSeconds; d != nil && missedRun.Add(time.Duration(*d)*time.Second).Before(now) {
+		log.Info("missed starting deadline for last run, sleeping till next")
+		return scheduledResult, nil
+	}
+
+	switch movie.Spec.ConcurrencyPolicy {
+	case corev1alpha1.ForbidConcurrent:
+		if len(scheduledPlays) > 0 {
+			log.Info("concurrency policy blocks concurrent runs, skipping", "num active", len(scheduledPlays))
+			return scheduledResult, nil
+		}
+	case corev1alpha1.ReplaceConcurrent:
+		// Cancelled Plays still play their closing credits
+		for _, play := range scheduledPlays {
+			if play.Spec.Cancel {
+				continue
+			}
+			play.Spec.Cancel = true
+			if err := r.Client.Update(ctx, play); client.IgnoreNotFound(err) != nil {
+				log.Error(err, "unable to cancel active play", "play", play.Name)
+				return ctrl.Result{}, err
+			}
+		}
+	}
+
+	play := generateScheduledPlay(*movie, missedRun)
+	if err := r.Client.Create(ctx, &play); err != nil && !errors.IsAlreadyExists(err) {
+		log.Error(err, "unable to create play for movie", "play", play.Name)
+		return ctrl.Result{}, err
+	}
+	log.V(1).Info("created play for movie run", "play", play.Name)
+
+	return scheduledResult, nil
+}
+
+// deleteOldPlays deletes the oldest finished Plays which exceed the history limit
+// and returns the ones which are retained
+func (r *MovieReconciler) deleteOldPlays(ctx context.Context, log logr.Logger, plays []*corev1alpha1.Play, limit *int32) []*corev1alpha1.Play {
+	if limit == nil || len(plays) <= int(*limit) {
+		return plays
+	}
+
+	sort.Slice(plays, func(i, j int) bool {
+		return playCompletionTime(plays[i]).Before(playCompletionTime(plays[j]))
+	})
+
+	var retained []*corev1alpha1.Play
+	for i, play := range plays {
+		if i >= len(plays)-int(*limit) {
+			retained = append(retained, play)
+			continue
+		}
+		if err := r.Client.Delete(ctx, play, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			log.Error(err, "unable to delete old play", "play", play.Name)
+			retained = append(retained, play)
+			continue
+		}
+		log.V(0).Info("deleted old play", "play", play.Name)
+	}
+	return retained
+}
+
+// updateHistory records the Plays of a Movie in its history and recalculates statistics of its runs
+func updateHistory(movie *corev1alpha1.Movie, plays []corev1alpha1.Play) {
+	records := make(map[string]corev1alpha1.PlayRecord)
+	for _, record := range movie.Status.History {
+		// Plays deleted before they finished are not interesting anymore
+		if record.CompletionTime != nil {
+			records[record.Name] = record
+		}
+	}
+	for i := range plays {
+		records[plays[i].Name] = newPlayRecord(&plays[i])
+	}
+
+	history := make([]corev1alpha1.PlayRecord, 0, len(records))
+	for _, record := range records {
+		history = append(history, record)
+	}
+	sort.Slice(history, func(i, j int) bool {
+		if history[i].StartTime == nil || history[j].StartTime == nil {
+			return history[j].StartTime != nil
+		}
+		return history[j].StartTime.Before(history[i].StartTime)
+	})
+	if len(history) > movieHistorySize {
+		history = history[:movieHistorySize]
+	}
+	movie.Status.History = history
+
+	var finished, successful int32
+	for _, record := range history {
+		if record.CompletionTime == nil {
+			continue
+		}
+		finished++
+		if record.Phase != corev1alpha1.PlayPhaseComplete {
+			continue
+		}
+		successful++
+		// History is ordered from the newest Play
+		if successful == 1 {
+			movie.Status.LastSuccessfulPlay = &corev1.ObjectReference{
+				APIVersion: corev1alpha1.GroupVersion.String(),
+				Kind:       "Play",
+				Namespace:  movie.Namespace,
+				Name:       record.Name,
+			}
+		}
+	}
+	movie.Status.SuccessRate = nil
+	if finished > 0 {
+		successRate := successful * 100 / finished
+		movie.Status.SuccessRate = &successRate
+	}
+}
+
+func newPlayRecord(play *corev1alpha1.Play) corev1alpha1.PlayRecord {
+	record := corev1alpha1.PlayRecord{
+		Name:           play.Name,
+		Phase:          play.Status.Phase,
+		StartTime:      play.Status.StartTime,
+		CompletionTime: play.Status.CompletionTime,
+	}
+	if record.StartTime == nil && !play.CreationTimestamp.IsZero() {
+		record.StartTime = play.CreationTimestamp.DeepCopy()
+	}
+	if record.StartTime != nil && record.CompletionTime != nil {
+		record.Duration = &metav1.Duration{Duration: record.CompletionTime.Sub(record.StartTime.Time)}
+	}
+	return record
+}
+
+func playCompletionTime(play *corev1alpha1.Play) time.Time {
+	if play.Status.CompletionTime != nil {
+		return play.Status.CompletionTime.Time
+	}
+	return play.CreationTimestamp.Time
+}
+
+func playScheduledTime(play *corev1alpha1.Play) (*time.Time, error) {
+	timeRaw := play.GetAnnotations()[PlayAnnotationScheduledAt]
+	if len(timeRaw) == 0 {
+		return nil, nil
+	}
+
+	timeParsed, err := time.Parse(time.RFC3339, timeRaw)
+	if err != nil {
+		return nil, err
+	}
+	return &timeParsed, nil
+}
+
+// nextSchedule returns the latest scheduled time of the Movie that was missed
+// (or zero time if none was missed) and the next time the Movie needs to be scheduled
+func nextSchedule(movie *corev1alpha1.Movie, now time.Time) (lastMissed time.Time, next time.Time, err error) {
+	sched, err := cron.ParseStandard(movie.Spec.Schedule)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("Unparseable schedule %q: %v", movie.Spec.Schedule, err)
+	}
+
+	var earliestTime time.Time
+	if movie.Status.LastScheduleTime != nil {
+		earliestTime = movie.Status.LastScheduleTime.Time
+	} else {
+		earliestTime = movie.ObjectMeta.CreationTimestamp.Time
+	}
+	if movie.Spec.StartingDeadlineSeconds != nil {
+		// Runs missed before the starting deadline can't be started anyway
+		schedulingDeadline := now.Add(-time.Second * time.Duration(*movie.Spec.StartingDeadlineSeconds))
+		if schedulingDeadline.After(earliestTime) {
+			earliestTime = schedulingDeadline
+		}
+	}
+	if earliestTime.After(now) {
+		return time.Time{}, sched.Next(now), nil
+	}
+
+	starts := 0
+	for t := sched.Next(earliestTime); !t.After(now); t = sched.Next(t) {
+		lastMissed = t
+		// Bail out instead of iterating over a huge number of missed runs,
+		// e.g. when the clock was skewed or the Movie has been around for long.
+		starts++
+		if starts > 100 {
+			return time.Time{}, time.Time{}, fmt.Errorf("Too many missed start times (> 100). Set or decrease .spec.startingDeadlineSeconds or check clock skew")
+		}
+	}
+	return lastMissed, sched.Next(now), nil
+}
+
+func generateScheduledPlay(movie corev1alpha1.Movie, scheduledTime time.Time) corev1alpha1.Play {
+	play := generatePlay(movie)
+	play.Name = fmt.Sprintf("%s-%d", movie.Name, scheduledTime.Unix())
+	if play.Annotations == nil {
+		play.Annotations = make(map[string]string)
+	}
+	play.Annotations[PlayAnnotationScheduledAt] = scheduledTime.Format(time.RFC3339)
+	return play
 }
 
 func (r *MovieReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.Clock == nil {
+		r.Clock = realClock{}
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&corev1alpha1.Movie{}).
+		Watches(&source.Kind{Type: &corev1alpha1.Play{}}, &handler.EnqueueRequestForOwner{
+			OwnerType:    &corev1alpha1.Movie{},
+			IsController: false,
+		}).
 		Complete(r)
 }
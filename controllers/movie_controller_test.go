@@ -0,0 +1,350 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+type fakeClock struct {
+	time time.Time
+}
+
+func (c fakeClock) Now() time.Time { return c.time }
+
+func newMovieReconciler(now time.Time) (*MovieReconciler, client.Client) {
+	s := scheme.Scheme
+	corev1alpha1.AddToScheme(s)
+	c := fake.NewFakeClientWithScheme(s)
+	return &MovieReconciler{
+		Client: c,
+		Scheme: s,
+		Log:    ctrl.Log.WithName("controllers").WithName("Movie"),
+		Clock:  fakeClock{now},
+	}, c
+}
+
+func scheduledMovie(name string, created time.Time) *corev1alpha1.Movie {
+	return &corev1alpha1.Movie{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			CreationTimestamp: metav1.Time{Time: created},
+		},
+		Spec: corev1alpha1.MovieSpec{
+			Schedule: "*/5 * * * *",
+			Template: corev1alpha1.PlayTemplate{
+				Spec: corev1alpha1.PlaySpec{
+					Screenplays: []corev1alpha1.Screenplay{{
+						Name: "main",
+					}},
+				},
+			},
+		},
+	}
+}
+
+func TestMovieSchedule(t *testing.T) {
+	created := time.Date(2020, 1, 1, 0, 1, 0, 0, time.UTC)
+	now := created.Add(6 * time.Minute)
+	r, c := newMovieReconciler(now)
+
+	movie := scheduledMovie("scheduled", created)
+	c.Create(context.TODO(), movie)
+
+	nn := types.NamespacedName{Name: movie.Name, Namespace: movie.Namespace}
+	result, err := r.Reconcile(reconcile.Request{NamespacedName: nn})
+	if err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+	if want := 3 * time.Minute; result.RequeueAfter != want {
+		t.Errorf("Want requeue after %v, got %v", want, result.RequeueAfter)
+	}
+
+	scheduledTime := time.Date(2020, 1, 1, 0, 5, 0, 0, time.UTC)
+	play := &corev1alpha1.Play{}
+	err = c.Get(context.TODO(), types.NamespacedName{
+		Name:      fmt.Sprintf("%s-%d", movie.Name, scheduledTime.Unix()),
+		Namespace: movie.Namespace,
+	}, play)
+	if err != nil {
+		t.Fatalf("Failed to find a scheduled play: %s", err)
+	}
+	if play.Labels[PlayLabelMovie] != movie.Name {
+		t.Errorf("Scheduled play is not labeled with the movie name")
+	}
+
+	if _, err := r.Reconcile(reconcile.Request{NamespacedName: nn}); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+	c.Get(context.TODO(), nn, movie)
+	if movie.Status.LastScheduleTime == nil || !movie.Status.LastScheduleTime.Time.Equal(scheduledTime) {
+		t.Errorf("Want last schedule time %v, got %v", scheduledTime, movie.Status.LastScheduleTime)
+	}
+	if len(movie.Status.Active) != 1 || movie.Status.Active[0].Name != play.Name {
+		t.Errorf("Want play %s to be active, got %v", play.Name, movie.Status.Active)
+	}
+}
+
+func TestMovieScheduleForbidConcurrent(t *testing.T) {
+	created := time.Date(2020, 1, 1, 0, 1, 0, 0, time.UTC)
+	r, c := newMovieReconciler(created.Add(6 * time.Minute))
+
+	movie := scheduledMovie("forbid-concurrent", created)
+	movie.Spec.ConcurrencyPolicy = corev1alpha1.ForbidConcurrent
+	c.Create(context.TODO(), movie)
+	c.Create(context.TODO(), &corev1alpha1.Play{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "running",
+			Namespace:   movie.Namespace,
+			Labels:      map[string]string{PlayLabelMovie: movie.Name},
+			Annotations: map[string]string{PlayAnnotationScheduledAt: created.Format(time.RFC3339)},
+		},
+		Status: corev1alpha1.PlayStatus{Phase: corev1alpha1.PlayPhaseRunning},
+	})
+
+	nn := types.NamespacedName{Name: movie.Name, Namespace: movie.Namespace}
+	if _, err := r.Reconcile(reconcile.Request{NamespacedName: nn}); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	plays := &corev1alpha1.PlayList{}
+	c.List(context.TODO(), plays, client.MatchingLabels{PlayLabelMovie: movie.Name})
+	if len(plays.Items) != 1 {
+		t.Errorf("Want no new plays to be scheduled while another one is running, got %d plays", len(plays.Items))
+	}
+}
+
+func TestMovieScheduleForbidConcurrentWithEventPlay(t *testing.T) {
+	created := time.Date(2020, 1, 1, 0, 1, 0, 0, time.UTC)
+	r, c := newMovieReconciler(created.Add(6 * time.Minute))
+
+	movie := scheduledMovie("forbid-concurrent", created)
+	movie.Spec.ConcurrencyPolicy = corev1alpha1.ForbidConcurrent
+	c.Create(context.TODO(), movie)
+	c.Create(context.TODO(), &corev1alpha1.Play{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "event",
+			Namespace: movie.Namespace,
+			Labels:    map[string]string{PlayLabelMovie: movie.Name},
+		},
+		Status: corev1alpha1.PlayStatus{Phase: corev1alpha1.PlayPhaseRunning},
+	})
+
+	nn := types.NamespacedName{Name: movie.Name, Namespace: movie.Namespace}
+	if _, err := r.Reconcile(reconcile.Request{NamespacedName: nn}); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	plays := &corev1alpha1.PlayList{}
+	c.List(context.TODO(), plays, client.MatchingLabels{PlayLabelMovie: movie.Name})
+	if len(plays.Items) != 2 {
+		t.Errorf("Want a play to be scheduled while a play of an event is running, got %d plays", len(plays.Items))
+	}
+}
+
+func TestMovieScheduleReplaceConcurrent(t *testing.T) {
+	created := time.Date(2020, 1, 1, 0, 1, 0, 0, time.UTC)
+	r, c := newMovieReconciler(created.Add(6 * time.Minute))
+
+	movie := scheduledMovie("replace-concurrent", created)
+	movie.Spec.ConcurrencyPolicy = corev1alpha1.ReplaceConcurrent
+	c.Create(context.TODO(), movie)
+	c.Create(context.TODO(), &corev1alpha1.Play{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "scheduled",
+			Namespace:   movie.Namespace,
+			Labels:      map[string]string{PlayLabelMovie: movie.Name},
+			Annotations: map[string]string{PlayAnnotationScheduledAt: created.Format(time.RFC3339)},
+		},
+		Status: corev1alpha1.PlayStatus{Phase: corev1alpha1.PlayPhaseRunning},
+	})
+	c.Create(context.TODO(), &corev1alpha1.Play{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "event",
+			Namespace: movie.Namespace,
+			Labels:    map[string]string{PlayLabelMovie: movie.Name},
+		},
+		Status: corev1alpha1.PlayStatus{Phase: corev1alpha1.PlayPhaseRunning},
+	})
+
+	nn := types.NamespacedName{Name: movie.Name, Namespace: movie.Namespace}
+	if _, err := r.Reconcile(reconcile.Request{NamespacedName: nn}); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	plays := &corev1alpha1.PlayList{}
+	c.List(context.TODO(), plays, client.MatchingLabels{PlayLabelMovie: movie.Name})
+	if len(plays.Items) != 3 {
+		t.Errorf("Want a new play to be scheduled, got %d plays", len(plays.Items))
+	}
+	for _, play := range plays.Items {
+		if want := play.Name == "scheduled"; play.Spec.Cancel != want {
+			t.Errorf("Want play %s cancelled %t, got %t", play.Name, want, play.Spec.Cancel)
+		}
+	}
+}
+
+func TestMovieScheduleStartingDeadline(t *testing.T) {
+	created := time.Date(2020, 1, 1, 0, 1, 0, 0, time.UTC)
+	movie := scheduledMovie("deadline", created)
+	deadline := int64(60)
+	movie.Spec.StartingDeadlineSeconds = &deadline
+
+	missed, next, err := nextSchedule(movie, created.Add(6*time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to compute schedule: %s", err)
+	}
+	if !missed.IsZero() {
+		t.Errorf("Want no missed runs past the starting deadline, got %v", missed)
+	}
+	if want := time.Date(2020, 1, 1, 0, 10, 0, 0, time.UTC); !next.Equal(want) {
+		t.Errorf("Want next run at %v, got %v", want, next)
+	}
+}
+
+func TestMovieHistoryLimits(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	r, c := newMovieReconciler(now)
+
+	successfulLimit, failedLimit := int32(1), int32(0)
+	movie := &corev1alpha1.Movie{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "history",
+			Namespace: "default",
+		},
+		Spec: corev1alpha1.MovieSpec{
+			SuccessfulJobsHistoryLimit: &successfulLimit,
+			FailedJobsHistoryLimit:     &failedLimit,
+		},
+	}
+	c.Create(context.TODO(), movie)
+
+	finishedPlay := func(name string, phase corev1alpha1.PlayPhaseType, completed time.Time) *corev1alpha1.Play {
+		return &corev1alpha1.Play{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: movie.Namespace,
+				Labels:    map[string]string{PlayLabelMovie: movie.Name},
+			},
+			Status: corev1alpha1.PlayStatus{
+				Phase:          phase,
+				CompletionTime: &metav1.Time{Time: completed},
+			},
+		}
+	}
+	for _, play := range []*corev1alpha1.Play{
+		finishedPlay("oldest", corev1alpha1.PlayPhaseComplete, now.Add(-3*time.Hour)),
+		finishedPlay("newest", corev1alpha1.PlayPhaseComplete, now.Add(-1*time.Hour)),
+		finishedPlay("older", corev1alpha1.PlayPhaseComplete, now.Add(-2*time.Hour)),
+		finishedPlay("failed", corev1alpha1.PlayPhaseFailed, now.Add(-1*time.Hour)),
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "running",
+				Namespace: movie.Namespace,
+				Labels:    map[string]string{PlayLabelMovie: movie.Name},
+			},
+			Status: corev1alpha1.PlayStatus{Phase: corev1alpha1.PlayPhaseRunning},
+		},
+	} {
+		c.Create(context.TODO(), play)
+	}
+
+	nn := types.NamespacedName{Name: movie.Name, Namespace: movie.Namespace}
+	if _, err := r.Reconcile(reconcile.Request{NamespacedName: nn}); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	plays := &corev1alpha1.PlayList{}
+	c.List(context.TODO(), plays, client.MatchingLabels{PlayLabelMovie: movie.Name})
+	remaining := make(map[string]bool)
+	for _, play := range plays.Items {
+		remaining[play.Name] = true
+	}
+	for name, want := range map[string]bool{
+		"oldest":  false,
+		"older":   false,
+		"newest":  true,
+		"failed":  false,
+		"running": true,
+	} {
+		if remaining[name] != want {
+			t.Errorf("Want play %s retained to be %v, got %v", name, want, remaining[name])
+		}
+	}
+
+	c.Get(context.TODO(), nn, movie)
+	if movie.Status.RetainedSuccessfulPlays != 1 || movie.Status.RetainedFailedPlays != 0 {
+		t.Errorf("Want 1 successful and 0 failed plays retained, got %d and %d", movie.Status.RetainedSuccessfulPlays, movie.Status.RetainedFailedPlays)
+	}
+}
+
+func TestMovieHistory(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	movie := &corev1alpha1.Movie{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "history",
+			Namespace: "default",
+		},
+		Status: corev1alpha1.MovieStatus{
+			History: []corev1alpha1.PlayRecord{{
+				Name:           "deleted",
+				Phase:          corev1alpha1.PlayPhaseComplete,
+				StartTime:      &metav1.Time{Time: now.Add(-5 * time.Hour)},
+				CompletionTime: &metav1.Time{Time: now.Add(-4 * time.Hour)},
+			}, {
+				Name:      "deleted-running",
+				Phase:     corev1alpha1.PlayPhaseRunning,
+				StartTime: &metav1.Time{Time: now.Add(-4 * time.Hour)},
+			}},
+		},
+	}
+
+	play := func(name string, phase corev1alpha1.PlayPhaseType, started time.Time, completed *time.Time) corev1alpha1.Play {
+		p := corev1alpha1.Play{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status: corev1alpha1.PlayStatus{
+				Phase:     phase,
+				StartTime: &metav1.Time{Time: started},
+			},
+		}
+		if completed != nil {
+			p.Status.CompletionTime = &metav1.Time{Time: *completed}
+		}
+		return p
+	}
+	successEnd := now.Add(-2 * time.Hour)
+	failedEnd := now.Add(-30 * time.Minute)
+	updateHistory(movie, []corev1alpha1.Play{
+		play("success", corev1alpha1.PlayPhaseComplete, now.Add(-3*time.Hour), &successEnd),
+		play("failed", corev1alpha1.PlayPhaseFailed, now.Add(-1*time.Hour), &failedEnd),
+		play("running", corev1alpha1.PlayPhaseRunning, now, nil),
+	})
+
+	var names []string
+	for _, record := range movie.Status.History {
+		names = append(names, record.Name)
+	}
+	if want := "[running failed success deleted]"; fmt.Sprint(names) != want {
+		t.Errorf("Want history %s, got %v", want, names)
+	}
+	if d := movie.Status.History[2].Duration; d == nil || d.Duration != time.Hour {
+		t.Errorf("Want duration of %v for a Play, got %v", time.Hour, d)
+	}
+	if rate := movie.Status.SuccessRate; rate == nil || *rate != 66 {
+		t.Errorf("Want success rate 66, got %v", rate)
+	}
+	if last := movie.Status.LastSuccessfulPlay; last == nil || last.Name != "success" {
+		t.Errorf("Want last successful play to be 'success', got %v", last)
+	}
+}
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
+	"github.com/kuberik/engine/pkg/engine"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// setPlayConditions updates conditions of a Play which are derived from its phase and frame statuses
+func setPlayConditions(play *corev1alpha1.Play) {
+	started := play.Status.Phase != "" && play.Status.Phase != corev1alpha1.PlayPhaseCreated && play.Status.Phase != corev1alpha1.PlayPhaseInit
+
+	if corev1alpha1.FindCondition(play.Status.Conditions, corev1alpha1.PlayConditionProvisioned) == nil {
+		setPlayCondition(play, corev1alpha1.PlayConditionProvisioned, corev1.ConditionFalse, corev1alpha1.PlayReasonPending, "")
+	}
+
+	if screenplay := play.Screenplay(corev1alpha1.MainScreenplayName); screenplay != nil {
+		var opening, closing []corev1alpha1.Frame
+		if screenplay.Credits != nil {
+			opening = screenplay.Credits.Opening
+			closing = screenplay.Credits.Closing
+		}
+
+		openingFinished, openingFailed := framesResult(&play.Status, opening)
+		switch {
+		case !started:
+			setPlayCondition(play, corev1alpha1.PlayConditionOpeningCreditsDone, corev1.ConditionFalse, corev1alpha1.PlayReasonPending, "")
+		case openingFinished:
+			setPlayCondition(play, corev1alpha1.PlayConditionOpeningCreditsDone, corev1.ConditionTrue, finishedReason(openingFailed), "")
+		default:
+			setPlayCondition(play, corev1alpha1.PlayConditionOpeningCreditsDone, corev1.ConditionFalse, corev1alpha1.PlayReasonInProgress, "")
+		}
+
+		scenesFinished, scenesFailed := true, false
+		for _, scene := range screenplay.Scenes {
+			finished, failed := framesResult(&play.Status, scene.Frames)
+			if failed && !screenplay.DAG {
+				// Remaining scenes are skipped
+				scenesFinished, scenesFailed = true, true
+				break
+			}
+			scenesFinished = scenesFinished && finished
+		}
+		scenesDone := openingFailed || (openingFinished && scenesFinished)
+		switch {
+		case !started:
+			setPlayCondition(play, corev1alpha1.PlayConditionScenesDone, corev1.ConditionFalse, corev1alpha1.PlayReasonPending, "")
+		case openingFailed:
+			setPlayCondition(play, corev1alpha1.PlayConditionScenesDone, corev1.ConditionTrue, corev1alpha1.PlayReasonSkipped, "Opening credits failed")
+		case !openingFinished:
+			setPlayCondition(play, corev1alpha1.PlayConditionScenesDone, corev1.ConditionFalse, corev1alpha1.PlayReasonPending, "")
+		case scenesFinished:
+			setPlayCondition(play, corev1alpha1.PlayConditionScenesDone, corev1.ConditionTrue, finishedReason(scenesFailed), "")
+		default:
+			setPlayCondition(play, corev1alpha1.PlayConditionScenesDone, corev1.ConditionFalse, corev1alpha1.PlayReasonInProgress, "")
+		}
+
+		closingFinished, closingFailed := framesResult(&play.Status, closing)
+		switch {
+		case !started || !scenesDone:
+			setPlayCondition(play, corev1alpha1.PlayConditionClosingCreditsDone, corev1.ConditionFalse, corev1alpha1.PlayReasonPending, "")
+		case closingFinished:
+			setPlayCondition(play, corev1alpha1.PlayConditionClosingCreditsDone, corev1.ConditionTrue, finishedReason(closingFailed), "")
+		default:
+			setPlayCondition(play, corev1alpha1.PlayConditionClosingCreditsDone, corev1.ConditionFalse, corev1alpha1.PlayReasonInProgress, "")
+		}
+	}
+
+	switch play.Status.Phase {
+	case corev1alpha1.PlayPhaseComplete, corev1alpha1.PlayPhaseFailed, corev1alpha1.PlayPhaseCancelled:
+		setPlayCondition(play, corev1alpha1.PlayConditionDeprovisioned, corev1.ConditionTrue, corev1alpha1.PlayReasonSucceeded, "")
+	default:
+		setPlayCondition(play, corev1alpha1.PlayConditionDeprovisioned, corev1.ConditionFalse, corev1alpha1.PlayReasonPending, "")
+	}
+
+	switch play.Status.Phase {
+	case corev1alpha1.PlayPhaseComplete:
+		setPlayCondition(play, corev1alpha1.PlayConditionReady, corev1.ConditionTrue, corev1alpha1.PlayReasonSucceeded, "Play completed successfully")
+	case corev1alpha1.PlayPhaseFailed:
+		setPlayCondition(play, corev1alpha1.PlayConditionReady, corev1.ConditionFalse, corev1alpha1.PlayReasonFailed, "Some of the frames failed")
+	case corev1alpha1.PlayPhaseError:
+		reason, message := corev1alpha1.PlayReasonFailed, ""
+		if c := corev1alpha1.FindCondition(play.Status.Conditions, corev1alpha1.PlayConditionError); c != nil {
+			reason, message = c.Reason, c.Message
+		}
+		setPlayCondition(play, corev1alpha1.PlayConditionReady, corev1.ConditionFalse, reason, message)
+	case corev1alpha1.PlayPhaseCancelled:
+		setPlayCondition(play, corev1alpha1.PlayConditionReady, corev1.ConditionFalse, corev1alpha1.PlayReasonCancelled, "Play was cancelled")
+	case corev1alpha1.PlayPhaseRunning:
+		if play.Spec.Suspend {
+			setPlayCondition(play, corev1alpha1.PlayConditionReady, corev1.ConditionFalse, corev1alpha1.PlayReasonSuspended, "Play doesn't start new frames while it's suspended")
+			break
+		}
+		setPlayCondition(play, corev1alpha1.PlayConditionReady, corev1.ConditionFalse, corev1alpha1.PlayReasonInProgress, "")
+	default:
+		setPlayCondition(play, corev1alpha1.PlayConditionReady, corev1.ConditionFalse, corev1alpha1.PlayReasonPending, "")
+	}
+}
+
+// setProvisionedCondition updates the Provisioned condition of a Play after a step of its Flow
+func setProvisionedCondition(play *corev1alpha1.Play, flowErr error) {
+	switch {
+	case engine.MessageForError(flowErr) == engine.ProvisionFailed:
+		setPlayCondition(play, corev1alpha1.PlayConditionProvisioned, corev1.ConditionFalse, corev1alpha1.PlayReasonProvisionFailed, flowErr.Error())
+	case flowErr == nil || engine.IsPlayEndedErorr(flowErr):
+		setPlayCondition(play, corev1alpha1.PlayConditionProvisioned, corev1.ConditionTrue, corev1alpha1.PlayReasonSucceeded, "")
+	}
+}
+
+func setPlayCondition(play *corev1alpha1.Play, conditionType string, status corev1.ConditionStatus, reason, message string) {
+	corev1alpha1.SetCondition(&play.Status.Conditions, corev1alpha1.Condition{
+		Type:    conditionType,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	})
+}
+
+// framesResult checks if all the frames finished and if any of them failed
+func framesResult(status *corev1alpha1.PlayStatus, frames []corev1alpha1.Frame) (finished bool, failed bool) {
+	finished = true
+	for _, frame := range frames {
+		state := status.FrameState(frame.ID)
+		finished = finished && state.Finished()
+		failed = failed || status.FrameFailed(frame.ID)
+	}
+	return
+}
+
+func finishedReason(failed bool) string {
+	if failed {
+		return corev1alpha1.PlayReasonFailed
+	}
+	return corev1alpha1.PlayReasonSucceeded
+}
@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"testing"
+
+	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestSetPlayConditions(t *testing.T) {
+	play := &corev1alpha1.Play{
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{{
+					Frames: []corev1alpha1.Frame{{ID: "a"}, {ID: "b"}},
+				}, {
+					Frames: []corev1alpha1.Frame{{ID: "c"}},
+				}},
+				Credits: &corev1alpha1.Credits{
+					Opening: []corev1alpha1.Frame{{ID: "opening"}},
+					Closing: []corev1alpha1.Frame{{ID: "closing"}},
+				},
+			}},
+		},
+		Status: corev1alpha1.PlayStatus{
+			Phase: corev1alpha1.PlayPhaseInit,
+		},
+	}
+
+	expect := func(conditionType string, status corev1.ConditionStatus, reason string) {
+		t.Helper()
+		condition := corev1alpha1.FindCondition(play.Status.Conditions, conditionType)
+		if condition == nil || condition.Status != status || condition.Reason != reason {
+			t.Errorf("Want %s condition %s (%s), got %v", conditionType, status, reason, condition)
+		}
+	}
+
+	setPlayConditions(play)
+	expect(corev1alpha1.PlayConditionProvisioned, corev1.ConditionFalse, corev1alpha1.PlayReasonPending)
+	expect(corev1alpha1.PlayConditionOpeningCreditsDone, corev1.ConditionFalse, corev1alpha1.PlayReasonPending)
+	expect(corev1alpha1.PlayConditionReady, corev1.ConditionFalse, corev1alpha1.PlayReasonPending)
+
+	play.Status.Phase = corev1alpha1.PlayPhaseRunning
+	setProvisionedCondition(play, nil)
+	play.Status.SetFrameState("opening", corev1alpha1.FrameStateSucceeded)
+	play.Status.SetFrameState("a", corev1alpha1.FrameStateSucceeded)
+	setPlayConditions(play)
+	expect(corev1alpha1.PlayConditionProvisioned, corev1.ConditionTrue, corev1alpha1.PlayReasonSucceeded)
+	expect(corev1alpha1.PlayConditionOpeningCreditsDone, corev1.ConditionTrue, corev1alpha1.PlayReasonSucceeded)
+	expect(corev1alpha1.PlayConditionScenesDone, corev1.ConditionFalse, corev1alpha1.PlayReasonInProgress)
+	expect(corev1alpha1.PlayConditionClosingCreditsDone, corev1.ConditionFalse, corev1alpha1.PlayReasonPending)
+	expect(corev1alpha1.PlayConditionReady, corev1.ConditionFalse, corev1alpha1.PlayReasonInProgress)
+
+	// Failed frame skips the rest of the scenes
+	play.Status.SetFrameState("b", corev1alpha1.FrameStateFailed)
+	setPlayConditions(play)
+	expect(corev1alpha1.PlayConditionScenesDone, corev1.ConditionTrue, corev1alpha1.PlayReasonFailed)
+	expect(corev1alpha1.PlayConditionClosingCreditsDone, corev1.ConditionFalse, corev1alpha1.PlayReasonInProgress)
+
+	play.Status.SetFrameState("closing", corev1alpha1.FrameStateSucceeded)
+	play.Status.Phase = corev1alpha1.PlayPhaseFailed
+	setPlayConditions(play)
+	expect(corev1alpha1.PlayConditionClosingCreditsDone, corev1.ConditionTrue, corev1alpha1.PlayReasonSucceeded)
+	expect(corev1alpha1.PlayConditionDeprovisioned, corev1.ConditionTrue, corev1alpha1.PlayReasonSucceeded)
+	expect(corev1alpha1.PlayConditionReady, corev1.ConditionFalse, corev1alpha1.PlayReasonFailed)
+}
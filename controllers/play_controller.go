@@ -19,11 +19,16 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/prometheus/common/log"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	ref "k8s.io/client-go/tools/reference"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -33,8 +38,17 @@ import (
 	"github.com/kuberik/engine/pkg/randutils"
 
 	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// jobNameLabel is a label which Job controller sets on Pods of a Job
+const jobNameLabel = "job-name"
+
+// PlayAnnotationRerun is name of an annotation which requests a rerun of a failed Play.
+// Every new value of the annotation reruns the Play once.
+const PlayAnnotationRerun = "core.kuberik.io/rerun"
+
 // PlayReconciler reconciles a Play object
 type PlayReconciler struct {
 	client.Client
@@ -46,6 +60,7 @@ type PlayReconciler struct {
 
 // +kubebuilder:rbac:groups=core.kuberik.io,resources=plays,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core.kuberik.io,resources=plays/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
 
 func (r *PlayReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	_ = r.Log.WithValues("play", req.NamespacedName)
@@ -67,7 +82,7 @@ func (r *PlayReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		return r.reconcileInit(instance)
 	case corev1alpha1.PlayPhaseRunning:
 		return r.reconcileRunning(instance)
-	case corev1alpha1.PlayPhaseComplete, corev1alpha1.PlayPhaseFailed, corev1alpha1.PlayPhaseError:
+	case corev1alpha1.PlayPhaseComplete, corev1alpha1.PlayPhaseFailed, corev1alpha1.PlayPhaseError, corev1alpha1.PlayPhaseCancelled:
 		return r.reconcileComplete(instance)
 	}
 	return reconcile.Result{}, nil
@@ -75,6 +90,7 @@ func (r *PlayReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 
 func (r *PlayReconciler) reconcileCreated(instance *corev1alpha1.Play) (reconcile.Result, error) {
 	instance.Status.Phase = corev1alpha1.PlayPhaseInit
+	setPlayConditions(instance)
 	err := r.Client.Status().Update(context.TODO(), instance)
 	return reconcile.Result{}, err
 }
@@ -88,6 +104,9 @@ func (r *PlayReconciler) reconcileInit(instance *corev1alpha1.Play) (reconcile.R
 
 	log.Info(fmt.Sprintf("Running play %s", instance.Name))
 	instance.Status.Phase = corev1alpha1.PlayPhaseRunning
+	now := metav1.Now()
+	instance.Status.StartTime = &now
+	setPlayConditions(instance)
 	err = r.Client.Status().Update(context.TODO(), instance)
 	if err != nil {
 		return reconcile.Result{}, err
@@ -101,19 +120,79 @@ func (r *PlayReconciler) reconcileRunning(instance *corev1alpha1.Play) (reconcil
 		return reconcile.Result{}, err
 	}
 
+	status := instance.Status.DeepCopy()
 	err := r.Flow.Next(instance)
+	setProvisionedCondition(instance, err)
+	if engine.MessageForError(err) == engine.ProvisionFailed {
+		// Provisioning is retried, failure is only recorded in the Provisioned condition
+		if !equality.Semantic.DeepEqual(status, &instance.Status) {
+			if updateErr := r.Client.Status().Update(context.TODO(), instance); updateErr != nil {
+				return reconcile.Result{}, updateErr
+			}
+		}
+		return reconcile.Result{}, err
+	}
+	if err != nil && !engine.IsPlayEndedErorr(err) && !engine.IsPlayFailedError(err) {
+		return reconcile.Result{}, err
+	}
+
 	if engine.IsPlayEndedErorr(err) {
-		if instance.Status.Failed() {
+		if instance.Spec.Cancel {
+			instance.Status.Phase = corev1alpha1.PlayPhaseCancelled
+		} else if instance.Status.Failed() {
 			instance.Status.Phase = corev1alpha1.PlayPhaseFailed
 		} else {
 			instance.Status.Phase = corev1alpha1.PlayPhaseComplete
 		}
+		now := metav1.Now()
+		instance.Status.CompletionTime = &now
+		setPlayConditions(instance)
 		return reconcile.Result{}, r.Client.Status().Update(context.TODO(), instance)
 	}
-	return reconcile.Result{}, err
+	if engine.IsPlayFailedError(err) {
+		log.Error(fmt.Sprintf("Play %s stopped: %s", instance.Name, err))
+		instance.Status.Phase = corev1alpha1.PlayPhaseError
+		corev1alpha1.SetCondition(&instance.Status.Conditions, corev1alpha1.Condition{
+			Type:    corev1alpha1.PlayConditionError,
+			Status:  corev1.ConditionTrue,
+			Reason:  playErrorReason(err),
+			Message: err.Error(),
+		})
+		now := metav1.Now()
+		instance.Status.CompletionTime = &now
+		setPlayConditions(instance)
+		return reconcile.Result{}, r.Client.Status().Update(context.TODO(), instance)
+	}
+
+	// Timeouts are enforced and frames are retried even if none of the Jobs changes.
+	// Play is reconciled a bit after the deadline to make sure that it passed.
+	result := reconcile.Result{}
+	for _, deadline := range []*time.Time{engine.NextTimeout(instance), engine.NextRetry(instance)} {
+		if deadline == nil {
+			continue
+		}
+		if requeueAfter := time.Until(*deadline) + time.Second; result.RequeueAfter == 0 || requeueAfter < result.RequeueAfter {
+			result.RequeueAfter = requeueAfter
+		}
+	}
+
+	// Results of finished stories are recorded by the Flow itself
+	setPlayConditions(instance)
+	if !equality.Semantic.DeepEqual(status, &instance.Status) {
+		return result, r.Client.Status().Update(context.TODO(), instance)
+	}
+	return result, nil
 }
 
 func (r *PlayReconciler) reconcileComplete(instance *corev1alpha1.Play) (reconcile.Result, error) {
+	rerun := instance.Annotations[PlayAnnotationRerun]
+	if instance.Status.Phase == corev1alpha1.PlayPhaseFailed && rerun != "" && rerun != instance.Status.LastRerun {
+		return r.reconcileRerun(instance, rerun)
+	}
+	if instance.Status.Phase == corev1alpha1.PlayPhaseError {
+		// Play which ended because of an error can't be played anymore
+		return reconcile.Result{}, nil
+	}
 	err := r.Flow.Next(instance)
 	if err != nil && !engine.IsPlayEndedErorr(err) {
 		return reconcile.Result{}, err
@@ -121,6 +200,27 @@ func (r *PlayReconciler) reconcileComplete(instance *corev1alpha1.Play) (reconci
 	return reconcile.Result{}, nil
 }
 
+func (r *PlayReconciler) reconcileRerun(instance *corev1alpha1.Play, rerun string) (reconcile.Result, error) {
+	log.Info(fmt.Sprintf("Rerunning play %s", instance.Name))
+	engine.Rerun(instance)
+	instance.Status.LastRerun = rerun
+	instance.Status.Phase = corev1alpha1.PlayPhaseRunning
+	now := metav1.Now()
+	instance.Status.StartTime = &now
+	instance.Status.CompletionTime = nil
+	setPlayConditions(instance)
+	return reconcile.Result{}, r.Client.Status().Update(context.TODO(), instance)
+}
+
+// playErrorReason returns the reason of a condition describing an error which stopped the Play
+func playErrorReason(err error) string {
+	switch engine.MessageForError(err) {
+	case engine.InvalidSpec:
+		return corev1alpha1.PlayReasonInvalidSpec
+	}
+	return ""
+}
+
 func (r *PlayReconciler) populateRandomIDs(play *corev1alpha1.Play) {
 	frames := play.AllFrames()
 	randomIDs := randutils.RandList(len(frames))
@@ -135,38 +235,117 @@ func (r *PlayReconciler) updateStatus(play *corev1alpha1.Play) error {
 		LabelSelector: engine.JobLabelSelector(play),
 	})
 
-	var updated bool
-	for _, j := range jobs.Items {
-		frameID := j.Annotations[engine.ActionAnnotationFrameID]
-		if _, ok := play.Status.Frames[frameID]; ok {
+	status := play.Status.DeepCopy()
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		frameID := job.Annotations[engine.ActionAnnotationFrameID]
+		// Jobs of earlier runs and attempts don't describe the current state of the frame
+		if play.Status.FrameState(frameID).Finished() || jobRun(job) != play.Status.Reruns ||
+			jobRetry(job) != len(play.Status.Frames[frameID].Retries) {
 			continue
 		}
 
-		updated = true
-		if status := frameStatus(&j); status != corev1alpha1.FrameStatusRunning {
-			play.Status.SetFrameStatus(frameID, status)
+		if err := r.updateFrameStatus(play, frameID, job); err != nil {
+			return err
 		}
 	}
 
-	if updated {
+	if !equality.Semantic.DeepEqual(status, &play.Status) {
 		return r.Client.Status().Update(context.TODO(), play)
 	}
 
 	return nil
 }
 
-func frameStatus(job *batchv1.Job) corev1alpha1.FrameStatus {
+// updateFrameStatus fills in the status of a frame from its Job and the Pods of the Job
+func (r *PlayReconciler) updateFrameStatus(play *corev1alpha1.Play, frameID string, job *batchv1.Job) error {
+	status := play.Status.Frames[frameID]
+	if status.Name == "" {
+		if frame := play.Frame(frameID); frame != nil {
+			status.Name = frame.Name
+		}
+	}
+	if status.JobRef == nil {
+		jobRef, err := ref.GetReference(r.Scheme, job)
+		if err != nil {
+			return err
+		}
+		status.JobRef = jobRef
+	}
+	status.Attempts = job.Status.Active + job.Status.Succeeded + job.Status.Failed
+	if job.Status.StartTime != nil {
+		status.StartTime = job.Status.StartTime
+	}
+	if job.Status.CompletionTime != nil {
+		status.CompletionTime = job.Status.CompletionTime
+	}
+
+	pods := &corev1.PodList{}
+	err := r.Client.List(context.TODO(), pods, client.InNamespace(job.Namespace), client.MatchingLabels{
+		jobNameLabel: job.Name,
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(pods.Items, func(i, j int) bool {
+		return pods.Items[i].CreationTimestamp.Before(&pods.Items[j].CreationTimestamp) ||
+			(pods.Items[i].CreationTimestamp.Equal(&pods.Items[j].CreationTimestamp) && pods.Items[i].Name < pods.Items[j].Name)
+	})
+	status.Pods = nil
+	for _, pod := range pods.Items {
+		status.Pods = append(status.Pods, pod.Name)
+	}
+	if len(pods.Items) > 0 {
+		// Exit codes of the latest attempt describe the result of the frame
+		status.ExitCodes, status.TerminationMessage = podTermination(&pods.Items[len(pods.Items)-1])
+	}
+
+	play.Status.SetFrameStatus(frameID, status)
+	play.Status.SetFrameState(frameID, frameState(job))
+	return nil
+}
+
+// podTermination collects exit codes and the termination message of terminated containers in a Pod
+func podTermination(pod *corev1.Pod) (exitCodes map[string]int32, message string) {
+	for _, container := range pod.Status.ContainerStatuses {
+		terminated := container.State.Terminated
+		if terminated == nil {
+			continue
+		}
+		if exitCodes == nil {
+			exitCodes = make(map[string]int32)
+		}
+		exitCodes[container.Name] = terminated.ExitCode
+		if terminated.Message != "" {
+			message = terminated.Message
+		}
+	}
+	return
+}
+
+// jobRun returns the number of the Play rerun which created the Job
+func jobRun(job *batchv1.Job) int32 {
+	run, _ := strconv.Atoi(job.Annotations[engine.ActionAnnotationRun])
+	return int32(run)
+}
+
+// jobRetry returns the number of the frame retry which created the Job
+func jobRetry(job *batchv1.Job) int {
+	retry, _ := strconv.Atoi(job.Annotations[engine.ActionAnnotationRetry])
+	return retry
+}
+
+func frameState(job *batchv1.Job) corev1alpha1.FrameState {
 	// Successfully completed a single instance of a job
 	for _, condition := range job.Status.Conditions {
-		if condition.Type == batchv1.JobFailed || condition.Type == batchv1.JobComplete {
-			if condition.Type == batchv1.JobComplete {
-				return corev1alpha1.FrameStatusSuccessful
-			} else {
-				return corev1alpha1.FrameStatusFailed
-			}
+		if condition.Type == batchv1.JobComplete {
+			return corev1alpha1.FrameStateSucceeded
+		}
+		if condition.Type == batchv1.JobFailed {
+			return corev1alpha1.FrameStateFailed
 		}
 	}
-	return corev1alpha1.FrameStatusRunning
+	return corev1alpha1.FrameStateRunning
 }
 
 func (r *PlayReconciler) SetupWithManager(mgr ctrl.Manager) error {
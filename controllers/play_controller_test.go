@@ -7,9 +7,11 @@ import (
 
 	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
 	"github.com/kuberik/engine/pkg/engine"
+	"github.com/kuberik/engine/pkg/engine/scheduler"
 	"github.com/kuberik/engine/pkg/engine/scheduler/k8s"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -18,6 +20,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/kustomize/api/resource"
 
 	"time"
 
@@ -222,6 +225,659 @@ func TestPlayRunning(t *testing.T) {
 	if play.Status.Phase != corev1alpha1.PlayPhaseComplete {
 		t.Errorf("Play state want %s, got %s", corev1alpha1.PlayPhaseComplete, play.Status.Phase)
 	}
+	for _, conditionType := range []string{
+		corev1alpha1.PlayConditionProvisioned,
+		corev1alpha1.PlayConditionOpeningCreditsDone,
+		corev1alpha1.PlayConditionScenesDone,
+		corev1alpha1.PlayConditionClosingCreditsDone,
+		corev1alpha1.PlayConditionDeprovisioned,
+		corev1alpha1.PlayConditionReady,
+	} {
+		condition := corev1alpha1.FindCondition(play.Status.Conditions, conditionType)
+		if condition == nil || condition.Status != corev1.ConditionTrue {
+			t.Errorf("Want %s condition to be true, got %v", conditionType, condition)
+		}
+	}
+}
+
+func TestPlayFrameStatus(t *testing.T) {
+	var (
+		name      = "frame-status"
+		namespace = "default"
+	)
+	play := &corev1alpha1.Play{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{{
+					Name: "test",
+					Frames: []corev1alpha1.Frame{{
+						ID:   "frame",
+						Name: "test",
+						Action: &corev1alpha1.Action{
+							Template: corev1.PodTemplateSpec{
+								Spec: corev1.PodSpec{
+									Containers: []corev1.Container{{
+										Name:    "test",
+										Command: []string{"false"},
+										Image:   "alpine",
+									}},
+								},
+							},
+						},
+					}},
+				}},
+			}},
+		},
+		Status: corev1alpha1.PlayStatus{
+			Phase: corev1alpha1.PlayPhaseRunning,
+		},
+	}
+	playClient.Create(context.TODO(), play)
+
+	nn := types.NamespacedName{
+		Name:      name,
+		Namespace: namespace,
+	}
+	req := reconcile.Request{
+		NamespacedName: nn,
+	}
+	if _, err := reconcilePlay.Reconcile(req); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	play = &corev1alpha1.Play{}
+	playClient.Get(context.TODO(), nn, play)
+	status := play.Status.Frames["frame"]
+	if status.State != corev1alpha1.FrameStateRunning || status.Name != "test" || status.Scene != "test" || status.Screenplay != "main" {
+		t.Errorf("Unexpected status of a started frame: %+v", status)
+	}
+
+	job := &batchv1.Job{}
+	err := playClient.Get(context.TODO(), types.NamespacedName{
+		Name:      fmt.Sprintf("test-%s", name),
+		Namespace: namespace,
+	}, job)
+	if err != nil {
+		t.Fatalf("Failed to find a job created by the Play: %s", err)
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-abcde", job.Name),
+			Namespace: namespace,
+			Labels: map[string]string{
+				jobNameLabel: job.Name,
+			},
+		},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name: "test",
+				State: corev1.ContainerState{
+					Terminated: &corev1.ContainerStateTerminated{
+						ExitCode: 1,
+						Message:  "test failed",
+					},
+				},
+			}},
+		},
+	}
+	playClient.Create(context.TODO(), pod)
+	job.Status.Failed = 1
+	job.Status.Conditions = append(job.Status.Conditions, batchv1.JobCondition{
+		Type:   batchv1.JobFailed,
+		Status: corev1.ConditionTrue,
+	})
+	playClient.Status().Update(context.TODO(), job)
+
+	if _, err := reconcilePlay.Reconcile(req); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	play = &corev1alpha1.Play{}
+	playClient.Get(context.TODO(), nn, play)
+	status = play.Status.Frames["frame"]
+	if status.State != corev1alpha1.FrameStateFailed {
+		t.Errorf("Frame state want %s, got %s", corev1alpha1.FrameStateFailed, status.State)
+	}
+	if status.Attempts != 1 {
+		t.Errorf("Frame attempts want 1, got %d", status.Attempts)
+	}
+	if status.JobRef == nil || status.JobRef.Name != job.Name {
+		t.Errorf("Frame job reference want %s, got %v", job.Name, status.JobRef)
+	}
+	if len(status.Pods) != 1 || status.Pods[0] != pod.Name {
+		t.Errorf("Frame pods want [%s], got %v", pod.Name, status.Pods)
+	}
+	if status.ExitCodes["test"] != 1 || status.TerminationMessage != "test failed" {
+		t.Errorf("Unexpected termination of the frame: %v, '%s'", status.ExitCodes, status.TerminationMessage)
+	}
+	if status.CompletionTime == nil {
+		t.Error("Frame completion time should be set")
+	}
+}
+
+func TestPlayFailFast(t *testing.T) {
+	var (
+		name      = "fail-fast"
+		namespace = "default"
+	)
+	action := &corev1alpha1.Action{
+		Template: corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name:    "test",
+					Command: []string{"echo", "test"},
+					Image:   "alpine",
+				}},
+			},
+		},
+	}
+	play := &corev1alpha1.Play{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: corev1alpha1.PlaySpec{
+			FailFast: true,
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{{
+					Name: "build",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "fast",
+						Name:   "fast",
+						Action: action,
+					}, {
+						ID:     "slow",
+						Name:   "slow",
+						Action: action,
+					}},
+				}},
+			}},
+		},
+		Status: corev1alpha1.PlayStatus{
+			Phase: corev1alpha1.PlayPhaseRunning,
+		},
+	}
+	playClient.Create(context.TODO(), play)
+
+	nn := types.NamespacedName{
+		Name:      name,
+		Namespace: namespace,
+	}
+	req := reconcile.Request{
+		NamespacedName: nn,
+	}
+	if _, err := reconcilePlay.Reconcile(req); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	job := &batchv1.Job{}
+	err := playClient.Get(context.TODO(), types.NamespacedName{
+		Name:      fmt.Sprintf("fast-%s", name),
+		Namespace: namespace,
+	}, job)
+	if err != nil {
+		t.Fatalf("Failed to find a job created by the Play: %s", err)
+	}
+	job.Status.Conditions = append(job.Status.Conditions, batchv1.JobCondition{
+		Type:   batchv1.JobFailed,
+		Status: corev1.ConditionTrue,
+	})
+	playClient.Status().Update(context.TODO(), job)
+
+	if _, err := reconcilePlay.Reconcile(req); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	err = playClient.Get(context.TODO(), types.NamespacedName{
+		Name:      fmt.Sprintf("slow-%s", name),
+		Namespace: namespace,
+	}, &batchv1.Job{})
+	if !errors.IsNotFound(err) {
+		t.Errorf("Job of the cancelled frame should be deleted, got %v", err)
+	}
+
+	play = &corev1alpha1.Play{}
+	playClient.Get(context.TODO(), nn, play)
+	if state := play.Status.FrameState("slow"); state != corev1alpha1.FrameStateCancelled {
+		t.Errorf("Frame state want %s, got %s", corev1alpha1.FrameStateCancelled, state)
+	}
+	if play.Status.Phase != corev1alpha1.PlayPhaseFailed {
+		t.Errorf("Play state want %s, got %s", corev1alpha1.PlayPhaseFailed, play.Status.Phase)
+	}
+}
+
+func TestPlayTimeoutRequeue(t *testing.T) {
+	var (
+		name      = "timeout"
+		namespace = "default"
+	)
+	play := &corev1alpha1.Play{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{{
+					Name: "test",
+					Frames: []corev1alpha1.Frame{{
+						ID:      "hung",
+						Name:    "hung",
+						Timeout: &metav1.Duration{Duration: time.Minute},
+						Action: &corev1alpha1.Action{
+							Template: corev1.PodTemplateSpec{
+								Spec: corev1.PodSpec{
+									Containers: []corev1.Container{{
+										Name:    "test",
+										Command: []string{"sleep", "infinity"},
+										Image:   "alpine",
+									}},
+								},
+							},
+						},
+					}},
+				}},
+			}},
+		},
+		Status: corev1alpha1.PlayStatus{
+			Phase: corev1alpha1.PlayPhaseRunning,
+		},
+	}
+	playClient.Create(context.TODO(), play)
+
+	result, err := reconcilePlay.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{
+		Name:      name,
+		Namespace: namespace,
+	}})
+	if err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+	if result.RequeueAfter <= 0 || result.RequeueAfter > time.Minute+time.Second {
+		t.Errorf("Play should be requeued before its frame times out, got %v", result.RequeueAfter)
+	}
+}
+
+func TestPlayCancel(t *testing.T) {
+	var (
+		name      = "cancel"
+		namespace = "default"
+	)
+	play := &corev1alpha1.Play{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: corev1alpha1.PlaySpec{
+			Cancel: true,
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{{
+					Name: "deploy",
+					Frames: []corev1alpha1.Frame{{
+						ID:   "deploy",
+						Name: "deploy",
+						Action: &corev1alpha1.Action{
+							Template: corev1.PodTemplateSpec{
+								Spec: corev1.PodSpec{
+									Containers: []corev1.Container{{
+										Name:    "deploy",
+										Command: []string{"echo", "deploy"},
+										Image:   "alpine",
+									}},
+								},
+							},
+						},
+					}},
+				}},
+			}},
+		},
+		Status: corev1alpha1.PlayStatus{
+			Phase: corev1alpha1.PlayPhaseRunning,
+		},
+	}
+	playClient.Create(context.TODO(), play)
+
+	nn := types.NamespacedName{
+		Name:      name,
+		Namespace: namespace,
+	}
+	if _, err := reconcilePlay.Reconcile(reconcile.Request{NamespacedName: nn}); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	play = &corev1alpha1.Play{}
+	playClient.Get(context.TODO(), nn, play)
+	if play.Status.Phase != corev1alpha1.PlayPhaseCancelled {
+		t.Errorf("Play state want %s, got %s", corev1alpha1.PlayPhaseCancelled, play.Status.Phase)
+	}
+	if state := play.Status.FrameState("deploy"); state != corev1alpha1.FrameStateCancelled {
+		t.Errorf("Frame state want %s, got %s", corev1alpha1.FrameStateCancelled, state)
+	}
+	condition := corev1alpha1.FindCondition(play.Status.Conditions, corev1alpha1.PlayConditionReady)
+	if condition == nil || condition.Reason != corev1alpha1.PlayReasonCancelled {
+		t.Errorf("Unexpected ready condition: %v", condition)
+	}
+}
+
+func TestPlayRerun(t *testing.T) {
+	var (
+		name      = "rerun"
+		namespace = "default"
+	)
+	play := &corev1alpha1.Play{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{{
+					Name: "deploy",
+					Frames: []corev1alpha1.Frame{{
+						ID:   "deploy",
+						Name: "deploy",
+						Action: &corev1alpha1.Action{
+							Template: corev1.PodTemplateSpec{
+								Spec: corev1.PodSpec{
+									Containers: []corev1.Container{{
+										Name:    "deploy",
+										Command: []string{"echo", "deploy"},
+										Image:   "alpine",
+									}},
+								},
+							},
+						},
+					}},
+				}},
+			}},
+		},
+		Status: corev1alpha1.PlayStatus{
+			Phase: corev1alpha1.PlayPhaseRunning,
+		},
+	}
+	playClient.Create(context.TODO(), play)
+
+	nn := types.NamespacedName{
+		Name:      name,
+		Namespace: namespace,
+	}
+	req := reconcile.Request{
+		NamespacedName: nn,
+	}
+	if _, err := reconcilePlay.Reconcile(req); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+	job := &batchv1.Job{}
+	if err := playClient.Get(context.TODO(), types.NamespacedName{Name: "deploy-rerun", Namespace: namespace}, job); err != nil {
+		t.Fatalf("Failed to find a job created by the Play: %s", err)
+	}
+	job.Status.Conditions = append(job.Status.Conditions, batchv1.JobCondition{
+		Type:   batchv1.JobFailed,
+		Status: corev1.ConditionTrue,
+	})
+	playClient.Status().Update(context.TODO(), job)
+	if _, err := reconcilePlay.Reconcile(req); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	play = &corev1alpha1.Play{}
+	playClient.Get(context.TODO(), nn, play)
+	if play.Status.Phase != corev1alpha1.PlayPhaseFailed {
+		t.Fatalf("Play state want %s, got %s", corev1alpha1.PlayPhaseFailed, play.Status.Phase)
+	}
+
+	play.Annotations = map[string]string{PlayAnnotationRerun: "1"}
+	playClient.Update(context.TODO(), play)
+	for i := 0; i < 2; i++ {
+		if _, err := reconcilePlay.Reconcile(req); err != nil {
+			t.Fatalf("reconcile: (%v)", err)
+		}
+	}
+
+	play = &corev1alpha1.Play{}
+	playClient.Get(context.TODO(), nn, play)
+	if play.Status.Phase != corev1alpha1.PlayPhaseRunning {
+		t.Errorf("Play state want %s, got %s", corev1alpha1.PlayPhaseRunning, play.Status.Phase)
+	}
+	if play.Status.Reruns != 1 || play.Status.LastRerun != "1" {
+		t.Errorf("Unexpected rerun status: %d, '%s'", play.Status.Reruns, play.Status.LastRerun)
+	}
+	// Failed Job of the previous run doesn't affect the rerun
+	if state := play.Status.FrameState("deploy"); state != corev1alpha1.FrameStateRunning {
+		t.Errorf("Frame state want %s, got %s", corev1alpha1.FrameStateRunning, state)
+	}
+	if err := playClient.Get(context.TODO(), types.NamespacedName{Name: "deploy-rerun-1-rerun", Namespace: namespace}, &batchv1.Job{}); err != nil {
+		t.Errorf("Failed to find a job created by the rerun: %s", err)
+	}
+}
+
+func TestPlayRetry(t *testing.T) {
+	var (
+		name      = "retry"
+		namespace = "default"
+	)
+	play := &corev1alpha1.Play{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{{
+					Name: "test",
+					Frames: []corev1alpha1.Frame{{
+						ID:   "test",
+						Name: "test",
+						Action: &corev1alpha1.Action{
+							Template: corev1.PodTemplateSpec{
+								Spec: corev1.PodSpec{
+									Containers: []corev1.Container{{
+										Name:    "test",
+										Command: []string{"echo", "test"},
+										Image:   "alpine",
+									}},
+								},
+							},
+						},
+						Retry: &corev1alpha1.RetryPolicy{
+							Limit: 1,
+							Backoff: &corev1alpha1.Backoff{
+								Base: metav1.Duration{Duration: time.Minute},
+							},
+						},
+					}},
+				}},
+			}},
+		},
+		Status: corev1alpha1.PlayStatus{
+			Phase: corev1alpha1.PlayPhaseRunning,
+		},
+	}
+	playClient.Create(context.TODO(), play)
+
+	nn := types.NamespacedName{
+		Name:      name,
+		Namespace: namespace,
+	}
+	req := reconcile.Request{
+		NamespacedName: nn,
+	}
+	if _, err := reconcilePlay.Reconcile(req); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+	job := &batchv1.Job{}
+	if err := playClient.Get(context.TODO(), types.NamespacedName{Name: "test-retry", Namespace: namespace}, job); err != nil {
+		t.Fatalf("Failed to find a job created by the Play: %s", err)
+	}
+	job.Status.Conditions = append(job.Status.Conditions, batchv1.JobCondition{
+		Type:   batchv1.JobFailed,
+		Status: corev1.ConditionTrue,
+	})
+	playClient.Status().Update(context.TODO(), job)
+	result, err := reconcilePlay.Reconcile(req)
+	if err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+	if result.RequeueAfter <= 0 || result.RequeueAfter > time.Minute+time.Second {
+		t.Errorf("Play should be requeued for the retry, got %s", result.RequeueAfter)
+	}
+
+	play = &corev1alpha1.Play{}
+	playClient.Get(context.TODO(), nn, play)
+	if play.Status.Phase != corev1alpha1.PlayPhaseRunning {
+		t.Errorf("Play state want %s, got %s", corev1alpha1.PlayPhaseRunning, play.Status.Phase)
+	}
+	status := play.Status.Frames["test"]
+	if status.State != corev1alpha1.FrameStatePending || len(status.Retries) != 1 {
+		t.Fatalf("Frame should wait for a retry, got state %s with %d retries", status.State, len(status.Retries))
+	}
+	if ref := status.Retries[0].JobRef; ref == nil || ref.Name != "test-retry" {
+		t.Errorf("Retry should reference the failed job, got %v", ref)
+	}
+
+	status.RetryAfter = &metav1.Time{Time: time.Now().Add(-time.Second)}
+	play.Status.SetFrameStatus("test", status)
+	playClient.Status().Update(context.TODO(), play)
+	for i := 0; i < 2; i++ {
+		if _, err := reconcilePlay.Reconcile(req); err != nil {
+			t.Fatalf("reconcile: (%v)", err)
+		}
+	}
+
+	play = &corev1alpha1.Play{}
+	playClient.Get(context.TODO(), nn, play)
+	// Failed Job of the previous attempt doesn't affect the retry
+	if state := play.Status.FrameState("test"); state != corev1alpha1.FrameStateRunning {
+		t.Errorf("Frame state want %s, got %s", corev1alpha1.FrameStateRunning, state)
+	}
+	if err := playClient.Get(context.TODO(), types.NamespacedName{Name: "test-retry-1-retry", Namespace: namespace}, &batchv1.Job{}); err != nil {
+		t.Errorf("Failed to find a job created by the retry: %s", err)
+	}
+}
+
+func TestPlayInvalidSpec(t *testing.T) {
+	var (
+		name      = "invalid-story"
+		namespace = "default"
+		story     = "missing"
+	)
+	play := &corev1alpha1.Play{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{{
+					Name: "test",
+					Frames: []corev1alpha1.Frame{{
+						Name:  "story",
+						Story: &story,
+					}},
+				}},
+			}},
+		},
+		Status: corev1alpha1.PlayStatus{
+			Phase: corev1alpha1.PlayPhaseRunning,
+		},
+	}
+	playClient.Create(context.TODO(), play)
+
+	nn := types.NamespacedName{
+		Name:      name,
+		Namespace: namespace,
+	}
+	_, err := reconcilePlay.Reconcile(reconcile.Request{NamespacedName: nn})
+	if err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	playClient.Get(context.TODO(), nn, play)
+	if play.Status.Phase != corev1alpha1.PlayPhaseError {
+		t.Errorf("Play state want %s, got %s", corev1alpha1.PlayPhaseError, play.Status.Phase)
+	}
+	condition := corev1alpha1.FindCondition(play.Status.Conditions, corev1alpha1.PlayConditionError)
+	if condition == nil || condition.Status != corev1.ConditionTrue || condition.Reason != corev1alpha1.PlayReasonInvalidSpec {
+		t.Errorf("Unexpected error condition: %v", condition)
+	}
+
+	// Play in error phase isn't played anymore
+	_, err = reconcilePlay.Reconcile(reconcile.Request{NamespacedName: nn})
+	if err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+}
+
+// provisionFailingScheduler fails to provision resources of Plays
+type provisionFailingScheduler struct {
+	scheduler.DummyScheduler
+}
+
+func (s *provisionFailingScheduler) Provision(resources []*resource.Resource) error {
+	return errors.NewServiceUnavailable("webhook unavailable")
+}
+
+func TestPlayProvisionFailed(t *testing.T) {
+	var (
+		name      = "provision-failed"
+		namespace = "default"
+	)
+	play := &corev1alpha1.Play{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{{
+					Name: "test",
+					Frames: []corev1alpha1.Frame{{
+						Name:   "test",
+						Action: &corev1alpha1.Action{},
+					}},
+				}},
+			}},
+		},
+		Status: corev1alpha1.PlayStatus{
+			Phase: corev1alpha1.PlayPhaseRunning,
+		},
+	}
+	playClient.Create(context.TODO(), play)
+
+	reconciler := &PlayReconciler{
+		Client: playClient,
+		Scheme: reconcilePlay.Scheme,
+		Log:    reconcilePlay.Log,
+		Flow:   engine.NewFlow(&provisionFailingScheduler{}),
+	}
+	nn := types.NamespacedName{
+		Name:      name,
+		Namespace: namespace,
+	}
+	// Failed provisioning is retried
+	if _, err := reconciler.Reconcile(reconcile.Request{NamespacedName: nn}); err == nil {
+		t.Fatalf("Want reconcile to fail while resources can't be provisioned")
+	}
+
+	playClient.Get(context.TODO(), nn, play)
+	if play.Status.Phase != corev1alpha1.PlayPhaseRunning {
+		t.Errorf("Play state want %s, got %s", corev1alpha1.PlayPhaseRunning, play.Status.Phase)
+	}
+	condition := corev1alpha1.FindCondition(play.Status.Conditions, corev1alpha1.PlayConditionProvisioned)
+	if condition == nil || condition.Status != corev1.ConditionFalse || condition.Reason != corev1alpha1.PlayReasonProvisionFailed {
+		t.Errorf("Unexpected provisioned condition: %v", condition)
+	}
 }
 
 func TestGetAllFramesWithCredits(t *testing.T) {
@@ -0,0 +1,295 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
+	"github.com/kuberik/engine/pkg/kubeutils"
+	"github.com/kuberik/engine/pkg/screener"
+)
+
+const (
+	// EventLabelScreener is name of a label which stores name of the Screener that created an Event
+	EventLabelScreener = "core.kuberik.io/screener"
+
+	// screenerRestartDelay is the time to wait before restarting a failed watcher
+	screenerRestartDelay = 30 * time.Second
+)
+
+// ScreenerReconciler reconciles a Screener object
+type ScreenerReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+
+	Registry *screener.Registry
+
+	lock     sync.Mutex
+	watchers map[types.NamespacedName]*screenerWatcher
+	// watcherEvents triggers reconciliation when a watcher changes its state
+	watcherEvents chan event.GenericEvent
+}
+
+// screenerWatcher tracks a running Screen of a single Screener object
+type screenerWatcher struct {
+	cancel     context.CancelFunc
+	generation int64
+	startTime  metav1.Time
+
+	lock          sync.Mutex
+	finished      bool
+	finishTime    time.Time
+	err           error
+	lastEventTime *metav1.Time
+}
+
+func (w *screenerWatcher) state() (finished bool, finishTime time.Time, err error, lastEventTime *metav1.Time) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.finished, w.finishTime, w.err, w.lastEventTime
+}
+
+// +kubebuilder:rbac:groups=core.kuberik.io,resources=screeners,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core.kuberik.io,resources=screeners/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=core.kuberik.io,resources=events,verbs=get;list;watch;create
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get
+
+func (r *ScreenerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("screener", req.NamespacedName)
+
+	instance := &corev1alpha1.Screener{}
+	err := r.Client.Get(ctx, req.NamespacedName, instance)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			r.stopWatcher(req.NamespacedName)
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	if !instance.DeletionTimestamp.IsZero() || !r.Registry.HasClass(instance.Spec.Class) {
+		// Screeners of other classes are handled by other controllers
+		r.stopWatcher(req.NamespacedName)
+		return ctrl.Result{}, nil
+	}
+
+	status := instance.Status.DeepCopy()
+	result := ctrl.Result{}
+	impl, ok := r.Registry.Screener(instance.Spec.Class, instance.Spec.Type)
+	if !ok {
+		r.stopWatcher(req.NamespacedName)
+		instance.Status.Phase = corev1alpha1.ScreenerPhaseUnsupported
+		instance.Status.Message = fmt.Sprintf("Screener type '%s' is not supported by class '%s'", instance.Spec.Type, instance.Spec.Class)
+		instance.Status.ObservedGeneration = instance.Generation
+	} else {
+		w := r.watcher(req.NamespacedName)
+		if w != nil && w.generation != instance.Generation {
+			log.Info("restarting watcher for a new generation of the screener")
+			r.stopWatcher(req.NamespacedName)
+			w = nil
+		}
+
+		if w != nil {
+			finished, finishTime, err, lastEventTime := w.state()
+			if lastEventTime != nil {
+				instance.Status.LastEventTime = lastEventTime
+			}
+			if finished {
+				if restartIn := screenerRestartDelay - time.Since(finishTime); restartIn > 0 {
+					instance.Status.Phase = corev1alpha1.ScreenerPhaseFailed
+					instance.Status.Message = fmt.Sprintf("Watcher stopped: %v", err)
+					result.RequeueAfter = restartIn
+				} else {
+					r.stopWatcher(req.NamespacedName)
+					w = nil
+				}
+			}
+		}
+
+		if w == nil {
+			log.Info("starting watcher for the screener")
+			w = r.startWatcher(instance, impl)
+			instance.Status.Phase = corev1alpha1.ScreenerPhaseRunning
+			instance.Status.Message = ""
+			instance.Status.ObservedGeneration = w.generation
+			instance.Status.StartTime = &w.startTime
+		}
+	}
+
+	if !equality.Semantic.DeepEqual(status, &instance.Status) {
+		if err := r.Client.Status().Update(ctx, instance); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return result, nil
+}
+
+func (r *ScreenerReconciler) watcher(nn types.NamespacedName) *screenerWatcher {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.watchers[nn]
+}
+
+func (r *ScreenerReconciler) startWatcher(instance *corev1alpha1.Screener, impl screener.Screener) *screenerWatcher {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &screenerWatcher{
+		cancel:     cancel,
+		generation: instance.Generation,
+		startTime:  metav1.Now(),
+	}
+	if r.watchers == nil {
+		r.watchers = make(map[types.NamespacedName]*screenerWatcher)
+	}
+	r.watchers[types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}] = w
+
+	target := instance.DeepCopy()
+	emitter := &screenerEventEmitter{
+		client:   r.Client,
+		screener: target,
+		emitted: func() {
+			now := metav1.Now()
+			w.lock.Lock()
+			w.lastEventTime = &now
+			w.lock.Unlock()
+			r.notify(ctx, target)
+		},
+	}
+	go func() {
+		err := impl.Screen(ctx, target, emitter)
+		if ctx.Err() != nil {
+			// Watcher was stopped by the reconciler
+			return
+		}
+		if err == nil {
+			err = fmt.Errorf("watcher exited")
+		}
+		r.Log.Error(err, "screener watcher stopped", "screener", types.NamespacedName{Name: target.Name, Namespace: target.Namespace})
+
+		w.lock.Lock()
+		w.finished = true
+		w.finishTime = time.Now()
+		w.err = err
+		w.lock.Unlock()
+		r.notify(ctx, target)
+	}()
+	return w
+}
+
+func (r *ScreenerReconciler) stopWatcher(nn types.NamespacedName) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if w, ok := r.watchers[nn]; ok {
+		w.cancel()
+		delete(r.watchers, nn)
+	}
+}
+
+// notify triggers reconciliation of a Screener after its watcher changed its state.
+// Notification is dropped if the watcher gets stopped while waiting for the controller.
+func (r *ScreenerReconciler) notify(ctx context.Context, screener *corev1alpha1.Screener) {
+	if r.watcherEvents == nil {
+		return
+	}
+	select {
+	case r.watcherEvents <- event.GenericEvent{Meta: screener, Object: screener}:
+	case <-ctx.Done():
+	}
+}
+
+// screenerEventEmitter creates Events for the Movie of a Screener
+type screenerEventEmitter struct {
+	client   client.Client
+	screener *corev1alpha1.Screener
+	emitted  func()
+}
+
+var _ screener.EventEmitter = &screenerEventEmitter{}
+
+// Emit implements screener.EventEmitter interface
+func (e *screenerEventEmitter) Emit(ctx context.Context, name string, data map[string]string) error {
+	event := &corev1alpha1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       e.screener.Namespace,
+			Labels:          map[string]string{EventLabelScreener: e.screener.Name},
+			OwnerReferences: []metav1.OwnerReference{kubeutils.OwnerReference(e.screener)},
+		},
+		Spec: corev1alpha1.EventSpec{
+			Movie: e.screener.Spec.Movie,
+			Data:  data,
+		},
+	}
+	if name == "" {
+		event.GenerateName = fmt.Sprintf("%s-", e.screener.Name)
+	}
+
+	err := e.client.Create(ctx, event)
+	if errors.IsAlreadyExists(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	e.emitted()
+	return nil
+}
+
+// SaveState implements screener.EventEmitter interface
+func (e *screenerEventEmitter) SaveState(ctx context.Context, state map[string]string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		instance := &corev1alpha1.Screener{}
+		if err := e.client.Get(ctx, types.NamespacedName{Name: e.screener.Name, Namespace: e.screener.Namespace}, instance); err != nil {
+			return err
+		}
+		instance.Status.State = state
+		return e.client.Status().Update(ctx, instance)
+	})
+}
+
+func (r *ScreenerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.watcherEvents = make(chan event.GenericEvent, 1024)
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&corev1alpha1.Screener{}).
+		Watches(&source.Channel{Source: r.watcherEvents}, &handler.EnqueueRequestForObject{}).
+		WithEventFilter(predicate.GenerationChangedPredicate{}).
+		Complete(r)
+}
@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
+	"github.com/kuberik/engine/pkg/screener"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const testScreenerClass = "test.kuberik.io"
+
+// onceScreener emits a single Event and waits until it's stopped
+type onceScreener struct {
+	emitted chan error
+}
+
+func (s *onceScreener) Screen(ctx context.Context, screener *corev1alpha1.Screener, emitter screener.EventEmitter) error {
+	s.emitted <- emitter.Emit(ctx, "once", map[string]string{"foo": "bar"})
+	<-ctx.Done()
+	return nil
+}
+
+func newScreenerReconciler(registry *screener.Registry) (*ScreenerReconciler, client.Client) {
+	s := scheme.Scheme
+	corev1alpha1.AddToScheme(s)
+	c := fake.NewFakeClientWithScheme(s)
+	return &ScreenerReconciler{
+		Client:   c,
+		Scheme:   s,
+		Log:      ctrl.Log.WithName("controllers").WithName("Screener"),
+		Registry: registry,
+	}, c
+}
+
+func TestScreenerWatcher(t *testing.T) {
+	impl := &onceScreener{emitted: make(chan error, 1)}
+	registry := screener.NewRegistry()
+	registry.Register(testScreenerClass, "once", impl)
+	r, c := newScreenerReconciler(registry)
+
+	instance := &corev1alpha1.Screener{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "once",
+			Namespace: "default",
+		},
+		Spec: corev1alpha1.ScreenerSpec{
+			Class: testScreenerClass,
+			Type:  "once",
+			Movie: "hello-world",
+		},
+	}
+	c.Create(context.TODO(), instance)
+
+	nn := types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}
+	if _, err := r.Reconcile(reconcile.Request{NamespacedName: nn}); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+	defer r.stopWatcher(nn)
+
+	select {
+	case err := <-impl.emitted:
+		if err != nil {
+			t.Fatalf("Failed to emit an event: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Screener didn't emit an event")
+	}
+
+	event := &corev1alpha1.Event{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: "once", Namespace: instance.Namespace}, event); err != nil {
+		t.Fatalf("Failed to find an event created by the screener: %s", err)
+	}
+	if event.Spec.Movie != instance.Spec.Movie || event.Spec.Data["foo"] != "bar" {
+		t.Errorf("Event created by the screener doesn't match emitted data: %v", event.Spec)
+	}
+
+	if _, err := r.Reconcile(reconcile.Request{NamespacedName: nn}); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+	c.Get(context.TODO(), nn, instance)
+	if instance.Status.Phase != corev1alpha1.ScreenerPhaseRunning {
+		t.Errorf("Want screener phase %s, got %s", corev1alpha1.ScreenerPhaseRunning, instance.Status.Phase)
+	}
+	if instance.Status.LastEventTime == nil {
+		t.Errorf("Time of the last event is not reported")
+	}
+}
+
+func TestScreenerUnsupportedType(t *testing.T) {
+	registry := screener.NewRegistry()
+	registry.Register(testScreenerClass, "once", &onceScreener{})
+	r, c := newScreenerReconciler(registry)
+
+	instance := &corev1alpha1.Screener{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "unsupported",
+			Namespace: "default",
+		},
+		Spec: corev1alpha1.ScreenerSpec{
+			Class: testScreenerClass,
+			Type:  "unsupported",
+			Movie: "hello-world",
+		},
+	}
+	c.Create(context.TODO(), instance)
+
+	nn := types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}
+	if _, err := r.Reconcile(reconcile.Request{NamespacedName: nn}); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+	c.Get(context.TODO(), nn, instance)
+	if instance.Status.Phase != corev1alpha1.ScreenerPhaseUnsupported {
+		t.Errorf("Want screener phase %s, got %s", corev1alpha1.ScreenerPhaseUnsupported, instance.Status.Phase)
+	}
+}
@@ -7,6 +7,7 @@ require (
 	github.com/onsi/ginkgo v1.12.1
 	github.com/onsi/gomega v1.10.1
 	github.com/prometheus/common v0.4.1
+	github.com/robfig/cron v1.2.0
 	github.com/sirupsen/logrus v1.4.2
 	k8s.io/api v0.18.6
 	k8s.io/apimachinery v0.18.6
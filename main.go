@@ -31,6 +31,10 @@ import (
 	"github.com/kuberik/engine/controllers"
 	"github.com/kuberik/engine/pkg/engine"
 	"github.com/kuberik/engine/pkg/engine/scheduler/k8s"
+	"github.com/kuberik/engine/pkg/screener"
+	"github.com/kuberik/engine/pkg/screener/git"
+	"github.com/kuberik/engine/pkg/screener/webhook"
+	"github.com/kuberik/engine/pkg/when"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -49,7 +53,9 @@ func init() {
 func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
+	var screenerWebhookAddr string
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
+	flag.StringVar(&screenerWebhookAddr, "screener-webhook-addr", ":8090", "The address webhook screeners are served on.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
@@ -95,6 +101,37 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "Play")
 		os.Exit(1)
 	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&corev1alpha1.Movie{}).SetupWebhookWithManager(mgr, when.Validator{}); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Movie")
+			os.Exit(1)
+		}
+		if err = (&corev1alpha1.Play{}).SetupWebhookWithManager(mgr, when.Validator{}); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Play")
+			os.Exit(1)
+		}
+		if err = (&corev1alpha1.Event{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Event")
+			os.Exit(1)
+		}
+	}
+	screeners := screener.NewRegistry()
+	webhookScreener := webhook.NewScreener(mgr.GetAPIReader(), screenerWebhookAddr)
+	if err := mgr.Add(webhookScreener); err != nil {
+		setupLog.Error(err, "unable to serve webhook screeners")
+		os.Exit(1)
+	}
+	utilruntime.Must(screeners.Register(screener.BuiltinClass, webhook.Type, webhookScreener))
+	utilruntime.Must(screeners.Register(screener.BuiltinClass, git.Type, &git.Screener{}))
+	if err = (&controllers.ScreenerReconciler{
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("Screener"),
+		Scheme:   mgr.GetScheme(),
+		Registry: screeners,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Screener")
+		os.Exit(1)
+	}
 	// +kubebuilder:scaffold:builder
 
 	setupLog.Info("starting manager")
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
 
 	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
 	"github.com/kuberik/engine/pkg/engine/internal/kustomize"
@@ -23,9 +24,120 @@ const (
 
 func provisionedResourcesLayer(play *corev1alpha1.Play, screenplay string) kustomize.KustomizeLayer {
 	kl := kustomize.NewKustomizeLayerRoot()
-	for _, p := range play.Spec.Screenplays[0].Provision.Resources {
+	addProvisionedResources(&kl, play, screenplay)
+	return kl
+}
+
+func addProvisionedResources(kl *kustomize.KustomizeLayer, play *corev1alpha1.Play, screenplay string) {
+	s := play.Screenplay(screenplay)
+	if s == nil {
+		return
+	}
+	for _, p := range s.Provision.Resources {
 		kl.AddObjectRaw(p.Raw)
 	}
+	// Volume claims are shared by all the screenplays, so they're provisioned with the main one
+	if screenplay == mainScreenplayName {
+		for _, template := range play.Spec.VolumeClaimTemplates {
+			kl.AddObject(volumeClaim(template))
+		}
+	}
+}
+
+// volumeClaim creates a PersistentVolumeClaim from a template
+func volumeClaim(template corev1.PersistentVolumeClaim) corev1.PersistentVolumeClaim {
+	return corev1.PersistentVolumeClaim{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "PersistentVolumeClaim",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        template.Name,
+			Labels:      template.Labels,
+			Annotations: template.Annotations,
+		},
+		Spec: template.Spec,
+	}
+}
+
+// addClaimVolumes adds volumes for volume claims of the Play which are mounted by containers of the Job.
+// Names of the claims are suffixed with name of the Play together with the provisioned resources.
+func addClaimVolumes(play *corev1alpha1.Play, job *batchv1.Job) {
+	podSpec := &job.Spec.Template.Spec
+	volumes := make(map[string]bool)
+	for _, volume := range podSpec.Volumes {
+		volumes[volume.Name] = true
+	}
+	mounts := make(map[string]bool)
+	for _, container := range append(append([]corev1.Container{}, podSpec.InitContainers...), podSpec.Containers...) {
+		for _, mount := range container.VolumeMounts {
+			mounts[mount.Name] = true
+		}
+	}
+	for _, template := range play.Spec.VolumeClaimTemplates {
+		if volumes[template.Name] || !mounts[template.Name] {
+			continue
+		}
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: template.Name,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: template.Name,
+				},
+			},
+		})
+	}
+}
+
+// screenplayPath returns names of the screenplays playing a screenplay as a story,
+// starting with the main screenplay and ending with the screenplay itself
+func screenplayPath(play *corev1alpha1.Play, screenplay string) []string {
+	var find func(path []string) []string
+	find = func(path []string) []string {
+		current := path[len(path)-1]
+		if current == screenplay {
+			return path
+		}
+		s := play.Screenplay(current)
+		if s == nil {
+			return nil
+		}
+		for _, frame := range screenplayFrames(s) {
+			if frame.Story == nil || containsString(path, *frame.Story) {
+				continue
+			}
+			if found := find(append(append([]string{}, path...), *frame.Story)); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	if path := find([]string{mainScreenplayName}); path != nil {
+		return path
+	}
+	return []string{mainScreenplayName, screenplay}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// actionResourcesLayer creates a layer with all the provisioned resources
+// which can be referenced by the actions of a screenplay, i.e. resources of
+// the screenplay and of all the screenplays playing it as a story
+func actionResourcesLayer(play *corev1alpha1.Play, screenplay string) kustomize.KustomizeLayer {
+	path := screenplayPath(play, screenplay)
+	kl := provisionedResourcesLayer(play, path[0])
+	for _, name := range path[1:] {
+		parent := kl
+		kl = parent.AddLayer()
+		addProvisionedResources(&kl, play, name)
+	}
 	return kl
 }
 
@@ -40,31 +152,71 @@ func generateFinalLayer(play *corev1alpha1.Play, layer kustomize.KustomizeLayer)
 	return rm.Resources(), nil
 }
 
+// generateProvisionedResources generates resources provisioned by a screenplay. Resources of the
+// screenplays playing it as a story are generated too so that they can be referenced, but they're
+// provisioned by their own screenplays.
 func generateProvisionedResources(play *corev1alpha1.Play, screenplay string) ([]*resource.Resource, error) {
-	return generateFinalLayer(play, provisionedResourcesLayer(play, screenplay))
+	own := provisionedResourcesLayer(play, screenplay)
+	ownResources, err := own.Run()
+	if err != nil {
+		return nil, err
+	}
+	provisioned := make(map[string]bool)
+	for _, r := range ownResources.Resources() {
+		provisioned[resourceKey(r.GetKind(), r.GetName())] = true
+	}
+
+	resources, err := generateFinalLayer(play, actionResourcesLayer(play, screenplay))
+	if err != nil {
+		return nil, err
+	}
+	var screenplayResources []*resource.Resource
+	for _, r := range resources {
+		if provisioned[resourceKey(r.GetKind(), r.GetOriginalName())] {
+			screenplayResources = append(screenplayResources, r)
+		}
+	}
+	return screenplayResources, nil
 }
 
-func generateActionJob(play *corev1alpha1.Play, screenplay string, frameID string) batchv1.Job {
-	pl := provisionedResourcesLayer(play, screenplay)
+func resourceKey(kind, name string) string {
+	return fmt.Sprintf("%s/%s", kind, name)
+}
 
-	action := newAction(play, frameID)
+func generateActionJob(play *corev1alpha1.Play, screenplay string, frameID string) (batchv1.Job, error) {
+	pl := actionResourcesLayer(play, screenplay)
+
+	// Outputs are substituted only in the action of the frame, before any values are injected into it
+	action, err := substituteOutputs(play, screenplay, *play.Frame(frameID).Action.DeepCopy())
+	if err != nil {
+		return batchv1.Job{}, WrapError(InvalidSpec, fmt.Errorf("failed creating a job for frame %s: %s", frameID, err))
+	}
+	addProvisionedConfigMaps(play, screenplay, &action)
+	addVars(play, screenplay, &action)
+	job := newAction(play, frameID, action)
+	addClaimVolumes(play, &job)
 	jl := pl.AddLayer()
-	jl.AddObject(action)
+	jl.AddObject(job)
 
 	resources, err := generateFinalLayer(play, jl)
 	if err != nil {
-		panic("failed creating a job")
+		return batchv1.Job{}, WrapError(InvalidSpec, fmt.Errorf("failed creating a job for frame %s: %s", frameID, err))
 	}
 	for _, r := range resources {
 		if r.GetKind() == "Job" {
 			transformedAction := batchv1.Job{}
-			transformedActionMarshaled, _ := json.Marshal(r)
-			json.Unmarshal(transformedActionMarshaled, &transformedAction)
-			return transformedAction
+			transformedActionMarshaled, err := json.Marshal(r)
+			if err != nil {
+				return batchv1.Job{}, WrapError(InvalidSpec, err)
+			}
+			if err := json.Unmarshal(transformedActionMarshaled, &transformedAction); err != nil {
+				return batchv1.Job{}, WrapError(InvalidSpec, err)
+			}
+			return transformedAction, nil
 		}
 	}
 
-	panic("transformation lost input action")
+	return batchv1.Job{}, WrapError(InvalidSpec, fmt.Errorf("transformation lost input action of frame %s", frameID))
 }
 
 var (
@@ -74,6 +226,10 @@ var (
 
 const (
 	ActionAnnotationFrameID = "core.kuberik.io/frameID"
+	// ActionAnnotationRun stores the number of the Play rerun which created the Job
+	ActionAnnotationRun = "core.kuberik.io/run"
+	// ActionAnnotationRetry stores the number of the retry of the frame which created the Job
+	ActionAnnotationRetry = "core.kuberik.io/retry"
 )
 
 func actionLabels(play *corev1alpha1.Play, frameName string) labels.Set {
@@ -89,9 +245,8 @@ func actionLabels(play *corev1alpha1.Play, frameName string) labels.Set {
 	}
 }
 
-func newAction(play *corev1alpha1.Play, frameID string) batchv1.Job {
+func newAction(play *corev1alpha1.Play, frameID string, e corev1alpha1.Action) batchv1.Job {
 	f := play.Frame(frameID)
-	e := f.Action
 
 	// TODO: replace with owner reference
 	annotations := map[string]string{
@@ -100,6 +255,9 @@ func newAction(play *corev1alpha1.Play, frameID string) batchv1.Job {
 	for k, v := range play.GetAnnotations() {
 		annotations[k] = v
 	}
+	annotations[ActionAnnotationRun] = strconv.Itoa(int(play.Status.Reruns))
+	retries := len(play.Status.Frames[frameID].Retries)
+	annotations[ActionAnnotationRetry] = strconv.Itoa(retries)
 
 	e.Template.Labels = labels.Merge(e.Template.Labels, actionLabels(play, f.Name))
 
@@ -110,6 +268,16 @@ func newAction(play *corev1alpha1.Play, frameID string) batchv1.Job {
 		e.Template.Spec.RestartPolicy = corev1.RestartPolicyNever
 	}
 
+	// Jobs of failed frames from previous runs are kept, so reruns need unique names
+	name := f.Name
+	if play.Status.Reruns > 0 {
+		name = fmt.Sprintf("%s-rerun-%d", f.Name, play.Status.Reruns)
+	}
+	// Jobs of failed attempts are kept as well
+	if retries > 0 {
+		name = fmt.Sprintf("%s-retry-%d", name, retries)
+	}
+
 	job := batchv1.Job{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "Job",
@@ -117,7 +285,7 @@ func newAction(play *corev1alpha1.Play, frameID string) batchv1.Job {
 		},
 		ObjectMeta: metav1.ObjectMeta{
 			// maximum string for job name is 63 characters.
-			Name:            f.Name,
+			Name:            name,
 			Namespace:       play.Namespace,
 			Annotations:     annotations,
 			Labels:          e.Template.Labels,
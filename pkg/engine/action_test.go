@@ -2,6 +2,8 @@ package engine
 
 import (
 	"fmt"
+	"reflect"
+	"sort"
 	"testing"
 
 	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
@@ -93,9 +95,179 @@ metadata:
 		},
 	}
 	provisioned, _ := generateProvisionedResources(play, screenplayName)
-	job := generateActionJob(play, screenplayName, "a")
+	job, err := generateActionJob(play, screenplayName, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if provisioned[0].GetName() != job.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName {
 		t.Errorf("Want '%s' name for provisioned resource, but got %s", provisioned[0].GetName(), job.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName)
 	}
 }
+
+func TestGenerateJobWithVolumeClaimTemplates(t *testing.T) {
+	screenplayName := "main"
+	play := &corev1alpha1.Play{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "ci",
+		},
+		Spec: corev1alpha1.PlaySpec{
+			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "workspace",
+				},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+				},
+			}, {
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "cache",
+				},
+			}},
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: screenplayName,
+				Scenes: []corev1alpha1.Scene{{
+					Frames: []corev1alpha1.Frame{{
+						Name: "a",
+						ID:   "a",
+						Action: &corev1alpha1.Action{
+							Template: corev1.PodTemplateSpec{
+								Spec: corev1.PodSpec{
+									Containers: []corev1.Container{{
+										Name: "build",
+										VolumeMounts: []corev1.VolumeMount{{
+											Name:      "workspace",
+											MountPath: "/workspace",
+										}},
+									}},
+								},
+							},
+						},
+					}},
+				}},
+			}},
+		},
+	}
+	provisioned, err := generateProvisionedResources(play, screenplayName)
+	if err != nil {
+		t.Fatalf("Failed to generate resources: %s", err)
+	}
+	if want := 2; len(provisioned) != want {
+		t.Fatalf("Want %d provisioned resources, but got %d", want, len(provisioned))
+	}
+	claims := make(map[string]bool)
+	for _, r := range provisioned {
+		if r.GetKind() != "PersistentVolumeClaim" || r.GetNamespace() != play.Namespace {
+			t.Errorf("Unexpected provisioned resource %s %s/%s", r.GetKind(), r.GetNamespace(), r.GetName())
+		}
+		claims[r.GetName()] = true
+	}
+
+	job, err := generateActionJob(play, screenplayName, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	volumes := job.Spec.Template.Spec.Volumes
+	if len(volumes) != 1 || volumes[0].Name != "workspace" || volumes[0].PersistentVolumeClaim == nil {
+		t.Fatalf("Want a single volume for the mounted claim, got %v", volumes)
+	}
+	if claimName := volumes[0].PersistentVolumeClaim.ClaimName; claimName != "workspace-test" || !claims[claimName] {
+		t.Errorf("Volume references claim %s which isn't provisioned", claimName)
+	}
+}
+
+func TestGenerateJobInNestedStory(t *testing.T) {
+	build, compile := "build", "compile"
+	play := &corev1alpha1.Play{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Provision: corev1alpha1.Provision{
+					Resources: []runtime.RawExtension{{
+						Raw: []byte(`{"apiVersion": "v1", "kind": "ServiceAccount", "metadata": {"name": "builder"}}`),
+					}},
+				},
+				Scenes: []corev1alpha1.Scene{{
+					Frames: []corev1alpha1.Frame{{ID: "a", Story: &build}},
+				}},
+			}, {
+				Name: build,
+				Provision: corev1alpha1.Provision{
+					Resources: []runtime.RawExtension{{
+						Raw: []byte(`{"apiVersion": "v1", "kind": "PersistentVolumeClaim", "metadata": {"name": "cache"}}`),
+					}, {
+						Raw: []byte(`{"apiVersion": "rbac.authorization.k8s.io/v1", "kind": "RoleBinding", "metadata": {"name": "builder"},
+							"roleRef": {"apiGroup": "rbac.authorization.k8s.io", "kind": "ClusterRole", "name": "edit"},
+							"subjects": [{"kind": "ServiceAccount", "name": "builder"}]}`),
+					}},
+				},
+				Scenes: []corev1alpha1.Scene{{
+					Frames: []corev1alpha1.Frame{{ID: "b", Story: &compile}},
+				}},
+			}, {
+				Name: compile,
+				Scenes: []corev1alpha1.Scene{{
+					Frames: []corev1alpha1.Frame{{
+						Name: "c",
+						ID:   "c",
+						Action: &corev1alpha1.Action{
+							Template: corev1.PodTemplateSpec{
+								Spec: corev1.PodSpec{
+									ServiceAccountName: "builder",
+									Volumes: []corev1.Volume{{
+										Name: "cache",
+										VolumeSource: corev1.VolumeSource{
+											PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+												ClaimName: "cache",
+											},
+										},
+									}},
+								},
+							},
+						},
+					}},
+				}},
+			}},
+		},
+	}
+
+	job, err := generateActionJob(play, compile, "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "builder-test"; job.Spec.Template.Spec.ServiceAccountName != want {
+		t.Errorf("Want service account %s provisioned by the main screenplay, got %s", want, job.Spec.Template.Spec.ServiceAccountName)
+	}
+	if want := "cache-test"; job.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName != want {
+		t.Errorf("Want claim %s provisioned by the parent story, got %s", want, job.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName)
+	}
+
+	for screenplay, want := range map[string][]string{
+		"main":  {"ServiceAccount/builder-test"},
+		build:   {"PersistentVolumeClaim/cache-test", "RoleBinding/builder-test"},
+		compile: nil,
+	} {
+		provisioned, err := generateProvisionedResources(play, screenplay)
+		if err != nil {
+			t.Fatalf("Failed to generate resources of %s: %s", screenplay, err)
+		}
+		var got []string
+		for _, r := range provisioned {
+			got = append(got, resourceKey(r.GetKind(), r.GetName()))
+			if r.GetKind() == "RoleBinding" {
+				subjects, _ := r.GetSlice("subjects")
+				if name := subjects[0].(map[string]interface{})["name"]; name != "builder-test" {
+					t.Errorf("Want role binding of service account builder-test, got %v", name)
+				}
+			}
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Want %v provisioned by %s, got %v", want, screenplay, got)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package engine
 
+import "fmt"
+
 // PlayRunErrorMessage represents a message that PlayRunError contains
 type PlayRunErrorMessage string
 
@@ -7,6 +9,13 @@ const (
 	// PlayFinished is a message provided when there's no more computing to do for the Play
 	PlayFinished PlayRunErrorMessage = "play finished"
 
+	// InvalidSpec is a message provided when the Play can't be played because of its spec
+	InvalidSpec PlayRunErrorMessage = "invalid spec"
+
+	// ProvisionFailed is a message provided when resources of the Play couldn't be provisioned.
+	// Provisioning is retried, since the failure is usually caused by the API server.
+	ProvisionFailed PlayRunErrorMessage = "provision failed"
+
 	// UnknownMessage is a message provided when unknown error happened
 	UnknownMessage PlayRunErrorMessage = ""
 )
@@ -14,17 +23,28 @@ const (
 // PlayRunError represents an error during the run
 type PlayRunError struct {
 	Message PlayRunErrorMessage
+
+	// Cause is the underlying error which caused the Play to stop
+	Cause error
 }
 
 var _ error = &PlayRunError{}
 
 func (e PlayRunError) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("%s: %s", e.Message, e.Cause)
+	}
 	return string(e.Message)
 }
 
 // NewError creates a new engine error with provided message
 func NewError(m PlayRunErrorMessage) PlayRunError {
-	return PlayRunError{m}
+	return PlayRunError{Message: m}
+}
+
+// WrapError creates a new engine error with provided message caused by another error
+func WrapError(m PlayRunErrorMessage, cause error) PlayRunError {
+	return PlayRunError{Message: m, Cause: cause}
 }
 
 // IsPlayEndedErorr checks if error indicates end of the play
@@ -36,6 +56,16 @@ func IsPlayEndedErorr(err error) bool {
 	return false
 }
 
+// IsPlayFailedError checks if error indicates that the play can't continue
+// and retrying it won't help
+func IsPlayFailedError(err error) bool {
+	switch MessageForError(err) {
+	case InvalidSpec:
+		return true
+	}
+	return false
+}
+
 // MessageForError returns the message for an error
 func MessageForError(err error) PlayRunErrorMessage {
 	switch t := err.(type) {
@@ -5,16 +5,21 @@ import (
 	"fmt"
 	"path"
 	"reflect"
+	"strings"
 
 	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
 	"github.com/kuberik/engine/pkg/engine/scheduler"
+	"github.com/kuberik/engine/pkg/when"
 	log "github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
 )
 
 const (
 	frameCopyIndexVar  = "FRAME_COPY_INDEX"
-	mainScreenplayName = "main"
+	mainScreenplayName = corev1alpha1.MainScreenplayName
+
+	// EventDataConfigMapName is name of the provisioned ConfigMap containing data of the Event which started the Play
+	EventDataConfigMapName = "event-data"
 )
 
 // Flow implements ordered exeuction of Actions in a Play
@@ -35,56 +40,156 @@ func NewFlow(scheduler scheduler.Scheduler) Flow {
 // Next executes the next aciton in the flow of a Play
 // This function should be called whenever a new Play event occurs
 func (f *Flow) Next(play *corev1alpha1.Play) error {
+	if err := validateStories(play, mainScreenplayName, nil, map[string]bool{}); err != nil {
+		return WrapError(InvalidSpec, err)
+	}
+	for i := range play.Spec.Screenplays {
+		if err := play.Spec.Screenplays[i].ValidateDependencies(); err != nil {
+			return WrapError(InvalidSpec, err)
+		}
+	}
+
 	// Expand definition
-	expandProvisionedConfigMaps(play)
 	expandCopies(&play.Spec)
+	expandForEach(play)
+	recordOutputs(play)
+	retryFrames(play)
+	if err := f.enforceTimeouts(play); err != nil {
+		return err
+	}
+	if play.Spec.Cancel {
+		if err := f.cancelPlay(play); err != nil {
+			return err
+		}
+	}
 	return f.playScreenplay(play, mainScreenplayName)
 }
 
+// validateStories checks that all the stories played from the screenplay
+// reference existing screenplays, that none of them references itself and that
+// every screenplay is played by a single story, since frames of a screenplay
+// share their statuses with all the stories playing it
+func validateStories(play *corev1alpha1.Play, name string, parents []string, referenced map[string]bool) error {
+	for _, parent := range parents {
+		if parent == name {
+			return fmt.Errorf("Screenplay '%s' is recursively referenced as a story", name)
+		}
+	}
+
+	screenplay := play.Screenplay(name)
+	if screenplay == nil {
+		return fmt.Errorf("Screenplay '%s' not found in the Play", name)
+	}
+
+	for _, frame := range screenplayFrames(screenplay) {
+		if frame.Story == nil {
+			continue
+		}
+		if referenced[*frame.Story] {
+			return fmt.Errorf("Screenplay '%s' is referenced by multiple stories", *frame.Story)
+		}
+		referenced[*frame.Story] = true
+		if err := validateStories(play, *frame.Story, append(parents, name), referenced); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func screenplayFrames(screenplay *corev1alpha1.Screenplay) (frames []corev1alpha1.Frame) {
+	if screenplay.Credits != nil {
+		frames = append(frames, screenplay.Credits.Opening...)
+	}
+	for _, scene := range screenplay.Scenes {
+		frames = append(frames, scene.Frames...)
+	}
+	if screenplay.Credits != nil {
+		frames = append(frames, screenplay.Credits.Closing...)
+	}
+	return
+}
+
 func framesFinished(status *corev1alpha1.PlayStatus, frames []corev1alpha1.Frame) bool {
 	sceneFinished := true
 	for _, frame := range frames {
-		_, ok := status.Frames[frame.ID]
-		sceneFinished = sceneFinished && ok
+		sceneFinished = sceneFinished && status.FrameState(frame.ID).Finished()
 	}
 	return sceneFinished
 }
 
-func (f *Flow) playScreenplay(play *corev1alpha1.Play, name string) error {
-	// TODO: Run only if screenplay didn't start (i.e. there's no condition for screenplay in progress)
-	if true {
-		provisionedResources, _ := generateProvisionedResources(play, name)
-		if err := f.Scheduler.Provision(provisionedResources); err != nil {
-			log.Errorf("provisioning error (play=%s/%s)", play.Namespace, play.Name)
-			return err
+func framesFailed(status *corev1alpha1.PlayStatus, frames []corev1alpha1.Frame) bool {
+	for _, frame := range frames {
+		if status.FrameFailed(frame.ID) {
+			return true
+		}
+	}
+	return false
+}
+
+// screenplayFailed checks if any of the frames played before closing credits of a screenplay failed
+func screenplayFailed(play *corev1alpha1.Play, screenplay *corev1alpha1.Screenplay) bool {
+	if screenplay.Credits != nil && framesFailed(&play.Status, screenplay.Credits.Opening) {
+		return true
+	}
+	for _, scene := range screenplay.Scenes {
+		if framesFailed(&play.Status, scene.Frames) {
+			return true
 		}
 	}
+	return false
+}
 
+func (f *Flow) playScreenplay(play *corev1alpha1.Play, name string) error {
 	screenplay := play.Screenplay(name)
 	if screenplay == nil {
-		return fmt.Errorf("Screenplay '%s' not found in the Play", name)
+		return WrapError(InvalidSpec, fmt.Errorf("Screenplay '%s' not found in the Play", name))
+	}
+
+	provisionedResources, err := generateProvisionedResources(play, name)
+	if err != nil {
+		return WrapError(InvalidSpec, fmt.Errorf("failed generating provisioned resources of screenplay '%s': %s", name, err))
 	}
 
-	if !play.Status.Failed() {
-		if screenplay.Credits != nil && !framesFinished(&play.Status, screenplay.Credits.Opening) {
-			return f.playFrames(play, screenplay.Credits.Opening)
+	// Resources of a finished screenplay were already deprovisioned
+	if frames := screenplayFrames(screenplay); len(frames) == 0 || !framesFinished(&play.Status, frames) {
+		if err := f.Scheduler.Provision(provisionedResources); err != nil {
+			log.Errorf("provisioning error (play=%s/%s)", play.Namespace, play.Name)
+			return WrapError(ProvisionFailed, err)
 		}
+	}
+
+	if err := f.failFast(play, name, screenplay); err != nil {
+		return err
+	}
 
+	openingFailed := screenplay.Credits != nil && framesFailed(&play.Status, screenplay.Credits.Opening)
+	switch {
+	case screenplay.Credits != nil && !framesFinished(&play.Status, screenplay.Credits.Opening) && !openingFailed:
+		return f.playFrames(play, name, "", screenplay.Credits.Opening)
+	case screenplay.DAG && !openingFailed:
+		// Failures of frames only affect frames depending on them
+		if !framesFinished(&play.Status, sceneFrames(screenplay)) {
+			return f.playGraph(play, name, screenplay)
+		}
+	case !screenplayFailed(play, screenplay):
 		for si := range screenplay.Scenes {
 			if framesFinished(&play.Status, screenplay.Scenes[si].Frames) {
 				continue
 			}
 
-			return f.playFrames(play, screenplay.Scenes[si].Frames)
+			return f.playFrames(play, name, screenplay.Scenes[si].Name, screenplay.Scenes[si].Frames)
+		}
+	default:
+		for _, scene := range screenplay.Scenes {
+			skipFrames(play, name, scene.Name, scene.Frames, "Skipped because an earlier frame failed")
 		}
 	}
 
 	if screenplay.Credits != nil && !framesFinished(&play.Status, screenplay.Credits.Closing) {
 		addScreenplayResult(screenplay.Credits.Closing, play, screenplay.Name)
-		return f.playFrames(play, screenplay.Credits.Closing)
+		return f.playFrames(play, name, "", screenplay.Credits.Closing)
 	}
 
-	provisionedResources, _ := generateProvisionedResources(play, name)
 	if err := f.Scheduler.Deprovision(provisionedResources); err != nil {
 		log.Errorf("deprovisioning error (play=%s/%s)", play.Namespace, play.Name)
 		return err
@@ -93,33 +198,325 @@ func (f *Flow) playScreenplay(play *corev1alpha1.Play, name string) error {
 	return NewError(PlayFinished)
 }
 
-func (f *Flow) playFrames(play *corev1alpha1.Play, frames []corev1alpha1.Frame) error {
+func (f *Flow) playFrames(play *corev1alpha1.Play, screenplay, scene string, frames []corev1alpha1.Frame) error {
 	for _, frame := range frames {
-		if _, ok := play.Status.Frames[frame.ID]; ok {
+		state := play.Status.FrameState(frame.ID)
+		// Stories are revisited until they finish to play their screenplays
+		if state.Finished() || (frame.Story == nil && state == corev1alpha1.FrameStateRunning) {
+			continue
+		}
+		if state == corev1alpha1.FrameStatePending && (play.Spec.Suspend || retryPending(play, frame.ID)) {
+			continue
+		}
+		// Frames iterating over outputs are played once they're expanded
+		if state == corev1alpha1.FrameStatePending && frame.ForEach != "" && frame.Action != nil {
+			finishForEach(play, screenplay, scene, frame)
 			continue
 		}
-		err := f.playFrame(play, frame.ID)
+		if state == corev1alpha1.FrameStatePending && frame.Action != nil {
+			ready, err := outputsReady(play, screenplay, frame)
+			if err != nil {
+				finishPendingFrames(play, screenplay, scene, []corev1alpha1.Frame{frame}, corev1alpha1.FrameStateFailed, "", err.Error())
+				continue
+			}
+			if !ready {
+				continue
+			}
+		}
+		if state == corev1alpha1.FrameStatePending && frame.When != "" {
+			ok, err := evaluateWhen(play, screenplay, frame)
+			if err != nil {
+				// Only the frame fails if its expression can't be evaluated, e.g. it references a frame of another screenplay
+				finishPendingFrames(play, screenplay, scene, []corev1alpha1.Frame{frame}, corev1alpha1.FrameStateFailed, "", fmt.Sprintf("Failed evaluating '%s': %s", frame.When, err))
+				continue
+			}
+			if !ok {
+				skipFrames(play, screenplay, scene, []corev1alpha1.Frame{frame}, fmt.Sprintf("Skipped because '%s' is false", frame.When))
+				continue
+			}
+		}
+		var err error
+		if frame.Story != nil {
+			err = f.playStory(play, frame)
+		} else {
+			err = f.playFrame(play, screenplay, frame.ID)
+		}
 		if err != nil {
 			return err
 		}
+		recordFrame(play, screenplay, scene, frame)
+	}
+	return nil
+}
+
+// playGraph plays frames of a screenplay played as a DAG. Frames are played as soon as
+// all the frames they depend on succeed and skipped if any of them doesn't succeed.
+func (f *Flow) playGraph(play *corev1alpha1.Play, name string, screenplay *corev1alpha1.Screenplay) error {
+	frameIDs := make(map[string]string)
+	for _, frame := range sceneFrames(screenplay) {
+		frameIDs[frame.Name] = frame.ID
+	}
+
+	for _, scene := range screenplay.Scenes {
+		var ready []corev1alpha1.Frame
+	frames:
+		for _, frame := range scene.Frames {
+			if play.Status.FrameState(frame.ID) != corev1alpha1.FrameStatePending {
+				ready = append(ready, frame)
+				continue
+			}
+			for _, dependency := range frame.DependsOn {
+				state := play.Status.FrameState(frameIDs[dependency])
+				if !state.Finished() {
+					continue frames
+				}
+				if state != corev1alpha1.FrameStateSucceeded && !play.Status.Frames[frameIDs[dependency]].FailureAllowed {
+					skipFrames(play, name, scene.Name, []corev1alpha1.Frame{frame}, fmt.Sprintf("Skipped because frame '%s' didn't succeed", dependency))
+					continue frames
+				}
+			}
+			ready = append(ready, frame)
+		}
+		if err := f.playFrames(play, name, scene.Name, ready); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sceneFrames(screenplay *corev1alpha1.Screenplay) (frames []corev1alpha1.Frame) {
+	for _, scene := range screenplay.Scenes {
+		frames = append(frames, scene.Frames...)
+	}
+	return
+}
+
+// failFast cancels running frames and skips pending frames of scenes
+// which need to stop once any of the frames fails
+func (f *Flow) failFast(play *corev1alpha1.Play, name string, screenplay *corev1alpha1.Screenplay) error {
+	failed := screenplayFailed(play, screenplay)
+	for _, scene := range screenplay.Scenes {
+		if (play.Spec.FailFast && failed) || (scene.FailFast && framesFailed(&play.Status, scene.Frames)) {
+			if err := f.cancelFrames(play, name, scene.Name, scene.Frames, corev1alpha1.FrameStateCancelled, "", "Cancelled because another frame failed"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// cancelPlay cancels all the frames of the main screenplay except its closing credits
+func (f *Flow) cancelPlay(play *corev1alpha1.Play) error {
+	screenplay := play.Screenplay(mainScreenplayName)
+	message := "Play was cancelled"
+	scenes := screenplay.Scenes
+	if screenplay.Credits != nil {
+		scenes = append([]corev1alpha1.Scene{{Frames: screenplay.Credits.Opening}}, scenes...)
+	}
+	for _, scene := range scenes {
+		finishPendingFrames(play, screenplay.Name, scene.Name, scene.Frames, corev1alpha1.FrameStateCancelled, "", message)
+		if err := f.cancelFrames(play, screenplay.Name, scene.Name, scene.Frames, corev1alpha1.FrameStateCancelled, "", message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cancelFrames stops running frames, including frames of running stories, and skips pending frames.
+// Stopped frames end up in the given state.
+func (f *Flow) cancelFrames(play *corev1alpha1.Play, screenplay, scene string, frames []corev1alpha1.Frame, state corev1alpha1.FrameState, reason, message string) error {
+	for _, frame := range frames {
+		if play.Status.FrameState(frame.ID) != corev1alpha1.FrameStateRunning {
+			continue
+		}
+		if frame.Story != nil {
+			story := play.Screenplay(*frame.Story)
+			scenes := story.Scenes
+			if story.Credits != nil {
+				scenes = append([]corev1alpha1.Scene{{Frames: story.Credits.Opening}}, scenes...)
+			}
+			for _, storyScene := range scenes {
+				finishPendingFrames(play, story.Name, storyScene.Name, storyScene.Frames, state, reason, message)
+				if err := f.cancelFrames(play, story.Name, storyScene.Name, storyScene.Frames, state, reason, message); err != nil {
+					return err
+				}
+			}
+			// Closing credits of the story clean up after it, so the story keeps running until they're played
+			if story.Credits != nil && !framesFinished(&play.Status, story.Credits.Closing) {
+				status := play.Status.Frames[frame.ID]
+				status.Reason = reason
+				status.Message = message
+				play.Status.SetFrameStatus(frame.ID, status)
+				continue
+			}
+		} else {
+			job, err := generateActionJob(play, screenplay, frame.ID)
+			if err != nil {
+				return err
+			}
+			if err := f.Scheduler.Cancel(job); err != nil {
+				log.Errorf("Failed to cancel %s from %s: %s", frame.ID, play.Name, err)
+				return err
+			}
+		}
+		status := play.Status.Frames[frame.ID]
+		status.Reason = reason
+		status.Message = message
+		play.Status.SetFrameStatus(frame.ID, status)
+		play.Status.SetFrameState(frame.ID, state)
 	}
+	skipFrames(play, screenplay, scene, frames, message)
 	return nil
 }
 
-func (f *Flow) playFrame(play *corev1alpha1.Play, frameID string) error {
-	err := f.Scheduler.Run(generateActionJob(play, mainScreenplayName, frameID))
+// recordFrame records which part of the play a played frame belongs to
+// and marks it as running unless it already finished
+func recordFrame(play *corev1alpha1.Play, screenplay, scene string, frame corev1alpha1.Frame) {
+	status := play.Status.Frames[frame.ID]
+	status.Name = frame.Name
+	status.Scene = scene
+	status.Screenplay = screenplay
+	status.FailureAllowed = frame.AllowFailure
+	started := status.State == "" || status.State == corev1alpha1.FrameStatePending
+	if started {
+		status.RetryAfter = nil
+	}
+	play.Status.SetFrameStatus(frame.ID, status)
+	if started {
+		play.Status.SetFrameState(frame.ID, corev1alpha1.FrameStateRunning)
+	}
+}
+
+// skipFrames marks frames which haven't started as skipped
+func skipFrames(play *corev1alpha1.Play, screenplay, scene string, frames []corev1alpha1.Frame, message string) {
+	finishPendingFrames(play, screenplay, scene, frames, corev1alpha1.FrameStateSkipped, "", message)
+}
+
+// finishPendingFrames moves frames which haven't started to a finished state
+func finishPendingFrames(play *corev1alpha1.Play, screenplay, scene string, frames []corev1alpha1.Frame, state corev1alpha1.FrameState, reason, message string) {
+	for _, frame := range frames {
+		if play.Status.FrameState(frame.ID) != corev1alpha1.FrameStatePending {
+			continue
+		}
+		play.Status.SetFrameStatus(frame.ID, corev1alpha1.FrameStatus{
+			Name:       frame.Name,
+			Scene:      scene,
+			Screenplay: screenplay,
+			Reason:     reason,
+			Message:    message,
+			Retries:    play.Status.Frames[frame.ID].Retries,
+		})
+		play.Status.SetFrameState(frame.ID, state)
+	}
+}
+
+// evaluateWhen evaluates the when expression of a frame
+func evaluateWhen(play *corev1alpha1.Play, screenplay string, frame corev1alpha1.Frame) (bool, error) {
+	expression, err := when.Parse(frame.When)
+	if err != nil {
+		return false, err
+	}
+
+	frames := when.Variables{}
+	for _, f := range screenplayFrames(play.Screenplay(screenplay)) {
+		outputs := play.Status.Frames[f.ID].Outputs
+		if outputs == nil {
+			outputs = map[string]string{}
+		}
+		frames[f.Name] = when.Variables{
+			"status":  strings.ToLower(string(play.Status.FrameState(f.ID))),
+			"output":  play.Status.Frames[f.ID].TerminationMessage,
+			"outputs": outputs,
+		}
+	}
+	annotations := play.Annotations
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	return expression.Evaluate(when.Variables{
+		"event":       eventData(play),
+		"annotations": annotations,
+		"frames":      frames,
+	})
+}
+
+// eventData returns data of the Event which started the Play
+func eventData(play *corev1alpha1.Play) map[string]string {
+	for _, screenplay := range play.Spec.Screenplays {
+		for _, resource := range screenplay.Provision.Resources {
+			cm := corev1.ConfigMap{}
+			if err := json.Unmarshal(resource.Raw, &cm); err != nil {
+				continue
+			}
+			if cm.Kind == reflect.TypeOf(cm).Name() && cm.Name == EventDataConfigMapName && cm.Data != nil {
+				return cm.Data
+			}
+		}
+	}
+	return map[string]string{}
+}
+
+func (f *Flow) playFrame(play *corev1alpha1.Play, screenplay string, frameID string) error {
+	job, err := generateActionJob(play, screenplay, frameID)
+	if err != nil {
+		return err
+	}
+	err = f.Scheduler.Run(job)
 	if err != nil {
 		log.Errorf("Failed to play %s from %s: %s", frameID, play.Name, err)
 	}
 	return err
 }
 
+// playStory plays the screenplay referenced by the story of a frame.
+// Once the screenplay finishes, its result is recorded as the status of the frame.
+func (f *Flow) playStory(play *corev1alpha1.Play, frame corev1alpha1.Frame) error {
+	err := f.playScreenplay(play, *frame.Story)
+	if !IsPlayEndedErorr(err) {
+		return err
+	}
+
+	screenplay := play.Screenplay(*frame.Story)
+	switch {
+	case screenplayFailed(play, screenplay) || (screenplay.Credits != nil && framesFailed(&play.Status, screenplay.Credits.Closing)):
+		play.Status.SetFrameState(frame.ID, corev1alpha1.FrameStateFailed)
+	case screenplayCancelled(play, screenplay):
+		play.Status.SetFrameState(frame.ID, corev1alpha1.FrameStateCancelled)
+	default:
+		play.Status.SetFrameState(frame.ID, corev1alpha1.FrameStateSucceeded)
+	}
+	return nil
+}
+
+// screenplayCancelled checks if any of the frames played before closing credits of a screenplay was cancelled
+func screenplayCancelled(play *corev1alpha1.Play, screenplay *corev1alpha1.Screenplay) bool {
+	frames := sceneFrames(screenplay)
+	if screenplay.Credits != nil {
+		frames = append(frames, screenplay.Credits.Opening...)
+	}
+	for _, frame := range frames {
+		if play.Status.FrameState(frame.ID) == corev1alpha1.FrameStateCancelled {
+			return true
+		}
+	}
+	return false
+}
+
 func expandCopies(playSpec *corev1alpha1.PlaySpec) {
 	for k := range playSpec.Screenplays {
+		copies := make(map[string][]string)
 		for si := range playSpec.Screenplays[k].Scenes {
 			var frames []corev1alpha1.Frame
 			for _, f := range playSpec.Screenplays[k].Scenes[si].Frames {
-				if f.Copies > 1 {
+				// Stories can't be copied since their frames are shared
+				if f.Matrix != nil && f.Action != nil {
+					// Frames depending on a matrix without combinations don't wait for it
+					copies[f.Name] = nil
+					for _, fc := range expandMatrix(f) {
+						frames = append(frames, fc)
+						copies[f.Name] = append(copies[f.Name], fc.Name)
+					}
+				} else if f.Copies > 1 && f.Action != nil {
 					for i := 0; i < f.Copies; i++ {
 						fc := f.DeepCopy()
 
@@ -132,6 +529,7 @@ func expandCopies(playSpec *corev1alpha1.PlaySpec) {
 							})
 						}
 						frames = append(frames, *fc)
+						copies[f.Name] = append(copies[f.Name], fc.Name)
 					}
 				} else {
 					frames = append(frames, f)
@@ -139,95 +537,116 @@ func expandCopies(playSpec *corev1alpha1.PlaySpec) {
 			}
 			playSpec.Screenplays[k].Scenes[si].Frames = frames
 		}
+		dependOnCopies(&playSpec.Screenplays[k], copies)
 	}
 }
 
-func expandProvisionedConfigMaps(play *corev1alpha1.Play) {
-	mountName := "kuberik-cms"
-	mountPath := "/kuberik/cms"
-	frames := play.AllFrames()
-	// TODO: KUB-75: this is gonna be wrong when there's nested screenplays
-	for _, cmRaw := range play.Spec.Screenplays[0].Provision.Resources {
-		cm := corev1.ConfigMap{}
-		json.Unmarshal(cmRaw.Raw, &cm)
-		if cm.Kind != reflect.TypeOf(cm).Name() {
+// dependOnCopies makes frames depending on a copied frame depend on all of its copies
+func dependOnCopies(screenplay *corev1alpha1.Screenplay, copies map[string][]string) {
+	for si := range screenplay.Scenes {
+		for fi := range screenplay.Scenes[si].Frames {
+			frame := &screenplay.Scenes[si].Frames[fi]
+			var dependsOn []string
+			for _, dependency := range frame.DependsOn {
+				if names, ok := copies[dependency]; ok {
+					dependsOn = append(dependsOn, names...)
+				} else {
+					dependsOn = append(dependsOn, dependency)
+				}
+			}
+			frame.DependsOn = dependsOn
+		}
+	}
+}
+
+// addProvisionedConfigMaps exposes ConfigMaps provisioned by a screenplay and by all the
+// screenplays playing it as a story to an action of the screenplay
+func addProvisionedConfigMaps(play *corev1alpha1.Play, screenplay string, action *corev1alpha1.Action) {
+	for _, name := range screenplayPath(play, screenplay) {
+		s := play.Screenplay(name)
+		if s == nil {
 			continue
 		}
-		for fi := range frames {
-			frames[fi].Action.Template.Spec.Volumes = append(
-				frames[fi].Action.Template.Spec.Volumes,
-				corev1.Volume{
-					Name: mountName,
-					VolumeSource: corev1.VolumeSource{
-						ConfigMap: &corev1.ConfigMapVolumeSource{
-							LocalObjectReference: corev1.LocalObjectReference{
-								Name: cm.Name,
-							},
-						},
+		for _, cmRaw := range s.Provision.Resources {
+			cm := corev1.ConfigMap{}
+			json.Unmarshal(cmRaw.Raw, &cm)
+			if cm.Kind != reflect.TypeOf(cm).Name() {
+				continue
+			}
+			injectConfigMap(action, cm.Name)
+		}
+	}
+}
+
+// injectConfigMap exposes a ConfigMap to containers of an action as environment variables
+// and as files in a directory named after the ConfigMap
+func injectConfigMap(action *corev1alpha1.Action, name string) {
+	mountName := fmt.Sprintf("kuberik-cms-%s", nameSuffix(name))
+	mountPath := "/kuberik/cms"
+	action.Template.Spec.Volumes = append(
+		action.Template.Spec.Volumes,
+		corev1.Volume{
+			Name: mountName,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: name,
 					},
 				},
-			)
-			for ci := range frames[fi].Action.Template.Spec.Containers {
-				frames[fi].Action.Template.Spec.Containers[ci].EnvFrom = append(
-					frames[fi].Action.Template.Spec.Containers[ci].EnvFrom,
-					corev1.EnvFromSource{
-						ConfigMapRef: &corev1.ConfigMapEnvSource{
-							LocalObjectReference: corev1.LocalObjectReference{
-								Name: cm.Name,
-							},
+			},
+		},
+	)
+	mutateContainers := func(containers []corev1.Container) {
+		for ci := range containers {
+			containers[ci].EnvFrom = append(
+				containers[ci].EnvFrom,
+				corev1.EnvFromSource{
+					ConfigMapRef: &corev1.ConfigMapEnvSource{
+						LocalObjectReference: corev1.LocalObjectReference{
+							Name: name,
 						},
 					},
-				)
-				frames[fi].Action.Template.Spec.Containers[ci].VolumeMounts = append(
-					frames[fi].Action.Template.Spec.Containers[ci].VolumeMounts,
-					corev1.VolumeMount{
-						Name:      mountName,
-						MountPath: path.Join(mountPath, cm.Name),
-					},
-				)
-			}
-			for ci := range frames[fi].Action.Template.Spec.InitContainers {
-				frames[fi].Action.Template.Spec.InitContainers[ci].EnvFrom = append(
-					frames[fi].Action.Template.Spec.InitContainers[ci].EnvFrom,
-					corev1.EnvFromSource{
-						ConfigMapRef: &corev1.ConfigMapEnvSource{
-							LocalObjectReference: corev1.LocalObjectReference{
-								Name: cm.Name,
-							},
-						},
-					},
-				)
-				frames[fi].Action.Template.Spec.InitContainers[ci].VolumeMounts = append(
-					frames[fi].Action.Template.Spec.InitContainers[ci].VolumeMounts,
-					corev1.VolumeMount{
-						Name:      mountName,
-						MountPath: path.Join(mountPath, cm.Name),
-					},
-				)
-			}
+				},
+			)
+			containers[ci].VolumeMounts = append(
+				containers[ci].VolumeMounts,
+				corev1.VolumeMount{
+					Name:      mountName,
+					MountPath: path.Join(mountPath, name),
+				},
+			)
 		}
 	}
+	mutateContainers(action.Template.Spec.Containers)
+	mutateContainers(action.Template.Spec.InitContainers)
 }
 
 const (
 	kuberikScreenplayResultEnv         = "KUBERIK_SCREENPLAY_RESULT"
 	kuberikScreenplayResultValueSucces = "success"
 	kuberikScreenplayResultValueFail   = "fail"
+	kuberikScreenplayResultValueCancel = "cancelled"
 )
 
 func addScreenplayResult(frames []corev1alpha1.Frame, play *corev1alpha1.Play, screenplayName string) {
 	var result string
 	for _, s := range play.Screenplay(screenplayName).Scenes {
 		for _, f := range s.Frames {
-			if play.Status.Frames[f.ID] == corev1alpha1.FrameStatusFailed {
+			if play.Status.FrameFailed(f.ID) {
 				result = kuberikScreenplayResultValueFail
 			}
 		}
 	}
+	if play.Spec.Cancel {
+		result = kuberikScreenplayResultValueCancel
+	}
 	if result == "" {
 		result = kuberikScreenplayResultValueSucces
 	}
 	for fi := range frames {
+		if frames[fi].Action == nil {
+			continue
+		}
 		mutateContainers := func(containers []corev1.Container) {
 			for ci := range containers {
 				containers[ci].Env = append(containers[ci].Env, corev1.EnvVar{
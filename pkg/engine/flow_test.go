@@ -1,16 +1,22 @@
 package engine
 
 import (
+	"fmt"
+	"reflect"
 	"testing"
 
 	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
 	"github.com/kuberik/engine/pkg/engine/scheduler"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/kustomize/api/resource"
 )
 
 var (
-	failed  = corev1alpha1.FrameStatusFailed
-	success = corev1alpha1.FrameStatusSuccessful
+	failed  = corev1alpha1.FrameStateFailed
+	success = corev1alpha1.FrameStateSucceeded
+	skipped = corev1alpha1.FrameStateSkipped
 )
 
 func helloWorldAction() *corev1alpha1.Action {
@@ -27,15 +33,15 @@ func helloWorldAction() *corev1alpha1.Action {
 	}
 }
 
-func assertFrameState(t *testing.T, play *corev1alpha1.Play, states map[string]*corev1alpha1.FrameStatus) {
+func assertFrameState(t *testing.T, play *corev1alpha1.Play, states map[string]*corev1alpha1.FrameState) {
 	for k, v := range states {
 		if v == nil {
-			if _, ok := play.Status.Frames[k]; ok {
-				t.Errorf("Excpected %s to not be played yet", k)
+			if r := play.Status.FrameState(k); r.Finished() {
+				t.Errorf("Excpected %s to not be finished yet, but got '%s'", k, r)
 			}
 		} else {
-			if r, ok := play.Status.Frames[k]; !ok || r != *v {
-				t.Errorf("Excpected %s to played and finished with status '%s', but got '%s'", k, v, r)
+			if r := play.Status.FrameState(k); r != *v {
+				t.Errorf("Excpected %s to played and finished with state '%s', but got '%s'", k, *v, r)
 			}
 		}
 	}
@@ -126,7 +132,7 @@ func TestNextLoop(t *testing.T) {
 	flow.Next(play)
 	// Mark "a" as not played
 	delete(play.Status.Frames, "a")
-	assertFrameState(t, play, map[string]*corev1alpha1.FrameStatus{
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
 		"a": nil,
 		"b": &success,
 		"c": nil,
@@ -134,7 +140,7 @@ func TestNextLoop(t *testing.T) {
 	})
 
 	flow.Next(play)
-	assertFrameState(t, play, map[string]*corev1alpha1.FrameStatus{
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
 		"a": &success,
 		"b": &success,
 		"c": nil,
@@ -142,7 +148,7 @@ func TestNextLoop(t *testing.T) {
 	})
 
 	flow.Next(play)
-	assertFrameState(t, play, map[string]*corev1alpha1.FrameStatus{
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
 		"a": &success,
 		"b": &success,
 		"c": &success,
@@ -195,7 +201,7 @@ func TestNextWithCredits(t *testing.T) {
 
 	flow := NewFlow(&scheduler.DummyScheduler{Play: play})
 	flow.Next(play)
-	assertFrameState(t, play, map[string]*corev1alpha1.FrameStatus{
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
 		"a": &success,
 		"b": nil,
 		"c": nil,
@@ -203,7 +209,7 @@ func TestNextWithCredits(t *testing.T) {
 	})
 
 	flow.Next(play)
-	assertFrameState(t, play, map[string]*corev1alpha1.FrameStatus{
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
 		"a": &success,
 		"b": &success,
 		"c": nil,
@@ -211,7 +217,7 @@ func TestNextWithCredits(t *testing.T) {
 	})
 
 	flow.Next(play)
-	assertFrameState(t, play, map[string]*corev1alpha1.FrameStatus{
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
 		"a": &success,
 		"b": &success,
 		"c": &success,
@@ -219,7 +225,7 @@ func TestNextWithCredits(t *testing.T) {
 	})
 
 	flow.Next(play)
-	assertFrameState(t, play, map[string]*corev1alpha1.FrameStatus{
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
 		"a": &success,
 		"b": &success,
 		"c": &success,
@@ -272,16 +278,16 @@ func TestNextFailedPlay(t *testing.T) {
 	flow.Next(play)
 	// Mark "a" as not played
 	delete(play.Status.Frames, "a")
-	assertFrameState(t, play, map[string]*corev1alpha1.FrameStatus{
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
 		"a": nil,
 		"b": &success,
 		"c": nil,
 		"d": nil,
 	})
 
-	flow = NewFlow(&scheduler.DummyScheduler{Play: play, Result: corev1alpha1.FrameStatusFailed})
+	flow = NewFlow(&scheduler.DummyScheduler{Play: play, Result: corev1alpha1.FrameStateFailed})
 	flow.Next(play)
-	assertFrameState(t, play, map[string]*corev1alpha1.FrameStatus{
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
 		"a": &failed,
 		"b": &success,
 		"c": nil,
@@ -292,11 +298,11 @@ func TestNextFailedPlay(t *testing.T) {
 	if !IsPlayEndedErorr(err) {
 		t.Errorf("Play should have ended")
 	}
-	assertFrameState(t, play, map[string]*corev1alpha1.FrameStatus{
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
 		"a": &failed,
 		"b": &success,
-		"c": nil,
-		"d": nil,
+		"c": &skipped,
+		"d": &skipped,
 	})
 }
 
@@ -324,10 +330,10 @@ func TestAddScreenplayResult(t *testing.T) {
 
 	var closingFrames []corev1alpha1.Frame
 	play.Status = corev1alpha1.PlayStatus{Frames: map[string]corev1alpha1.FrameStatus{
-		"a": corev1alpha1.FrameStatusSuccessful,
-		"b": corev1alpha1.FrameStatusFailed,
-		"d": corev1alpha1.FrameStatusFailed,
-		"e": corev1alpha1.FrameStatusFailed,
+		"a": {State: corev1alpha1.FrameStateSucceeded},
+		"b": {State: corev1alpha1.FrameStateFailed},
+		"d": {State: corev1alpha1.FrameStateFailed},
+		"e": {State: corev1alpha1.FrameStateFailed},
 	}}
 	closingFrames = []corev1alpha1.Frame{{
 		Action: helloWorldAction(),
@@ -338,11 +344,11 @@ func TestAddScreenplayResult(t *testing.T) {
 	}
 
 	play.Status = corev1alpha1.PlayStatus{Frames: map[string]corev1alpha1.FrameStatus{
-		"a": corev1alpha1.FrameStatusSuccessful,
-		"b": corev1alpha1.FrameStatusSuccessful,
-		"c": corev1alpha1.FrameStatusSuccessful,
-		"d": corev1alpha1.FrameStatusFailed,
-		"e": corev1alpha1.FrameStatusFailed,
+		"a": {State: corev1alpha1.FrameStateSucceeded},
+		"b": {State: corev1alpha1.FrameStateSucceeded},
+		"c": {State: corev1alpha1.FrameStateSucceeded},
+		"d": {State: corev1alpha1.FrameStateFailed},
+		"e": {State: corev1alpha1.FrameStateFailed},
 	}}
 	closingFrames = []corev1alpha1.Frame{{
 		Action: helloWorldAction(),
@@ -352,3 +358,913 @@ func TestAddScreenplayResult(t *testing.T) {
 		t.Errorf("Expected to find %s env with status %s", kuberikScreenplayResultEnv, kuberikScreenplayResultValueSucces)
 	}
 }
+
+func TestNextWithStory(t *testing.T) {
+	story := "build"
+	play := &corev1alpha1.Play{
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{
+					{
+						Name: "first-scene",
+						Frames: []corev1alpha1.Frame{
+							{
+								ID:    "a",
+								Name:  "build",
+								Story: &story,
+							},
+							{
+								ID:     "b",
+								Name:   "first-hello-b",
+								Action: helloWorldAction(),
+							},
+						},
+					},
+					{
+						Name: "second-scene",
+						Frames: []corev1alpha1.Frame{
+							{
+								ID:     "c",
+								Name:   "second-hello-a",
+								Action: helloWorldAction(),
+							},
+						},
+					},
+				},
+			}, {
+				Name: story,
+				Scenes: []corev1alpha1.Scene{
+					{
+						Name: "build-scene",
+						Frames: []corev1alpha1.Frame{
+							{
+								ID:     "d",
+								Name:   "build-hello-a",
+								Action: helloWorldAction(),
+							},
+						},
+					},
+					{
+						Name: "push-scene",
+						Frames: []corev1alpha1.Frame{
+							{
+								ID:     "e",
+								Name:   "push-hello-a",
+								Action: helloWorldAction(),
+							},
+						},
+					},
+				},
+			}},
+		},
+	}
+
+	flow := NewFlow(&scheduler.DummyScheduler{Play: play})
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"a": nil,
+		"b": &success,
+		"c": nil,
+		"d": &success,
+		"e": nil,
+	})
+
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"a": nil,
+		"b": &success,
+		"c": nil,
+		"d": &success,
+		"e": &success,
+	})
+
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"a": &success,
+		"b": &success,
+		"c": nil,
+		"d": &success,
+		"e": &success,
+	})
+
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"a": &success,
+		"b": &success,
+		"c": &success,
+		"d": &success,
+		"e": &success,
+	})
+
+	err := flow.Next(play)
+	if !IsPlayEndedErorr(err) {
+		t.Errorf("Play should have ended")
+	}
+}
+
+// provisioningScheduler records provisioning of resources by their names
+type provisioningScheduler struct {
+	scheduler.DummyScheduler
+	events []string
+}
+
+func (s *provisioningScheduler) Provision(resources []*resource.Resource) error {
+	for _, r := range resources {
+		s.events = append(s.events, fmt.Sprintf("provision %s", r.GetName()))
+	}
+	return nil
+}
+
+func (s *provisioningScheduler) Deprovision(resources []*resource.Resource) error {
+	for _, r := range resources {
+		s.events = append(s.events, fmt.Sprintf("deprovision %s", r.GetName()))
+	}
+	return nil
+}
+
+func configMapResource(name string) runtime.RawExtension {
+	return runtime.RawExtension{
+		Raw: []byte(fmt.Sprintf(`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "%s"}}`, name)),
+	}
+}
+
+func TestNextProvisionsStoryResources(t *testing.T) {
+	story := "build"
+	play := &corev1alpha1.Play{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Provision: corev1alpha1.Provision{
+					Resources: []runtime.RawExtension{configMapResource("settings")},
+				},
+				Scenes: []corev1alpha1.Scene{{
+					Name: "build",
+					Frames: []corev1alpha1.Frame{{
+						ID:    "a",
+						Name:  "build",
+						Story: &story,
+					}},
+				}, {
+					Name: "deploy",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "b",
+						Name:   "deploy",
+						Action: helloWorldAction(),
+					}},
+				}},
+			}, {
+				Name: story,
+				Provision: corev1alpha1.Provision{
+					Resources: []runtime.RawExtension{configMapResource("cache")},
+				},
+				Scenes: []corev1alpha1.Scene{{
+					Name: "compile",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "c",
+						Name:   "compile",
+						Action: helloWorldAction(),
+					}},
+				}},
+			}},
+		},
+	}
+
+	s := &provisioningScheduler{DummyScheduler: scheduler.DummyScheduler{Play: play}}
+	flow := NewFlow(s)
+	for i := 0; i < 10; i++ {
+		if err := flow.Next(play); IsPlayEndedErorr(err) {
+			break
+		}
+	}
+	if !play.Status.FrameState("b").Finished() {
+		t.Fatalf("Play should have finished")
+	}
+	// Finished screenplays aren't provisioned again
+	flow.Next(play)
+
+	var events []string
+	for _, event := range s.events {
+		if len(events) == 0 || events[len(events)-1] != event {
+			events = append(events, event)
+		}
+	}
+	want := []string{
+		"provision settings-test",
+		"provision cache-test",
+		"provision settings-test",
+		"deprovision cache-test",
+		"provision settings-test",
+		"deprovision settings-test",
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("Want provisioning events %v, got %v", want, events)
+	}
+}
+
+func TestGenerateJobWithProvisionedConfigMaps(t *testing.T) {
+	build, deploy := "build", "deploy"
+	play := &corev1alpha1.Play{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Provision: corev1alpha1.Provision{
+					Resources: []runtime.RawExtension{configMapResource("settings")},
+				},
+				Scenes: []corev1alpha1.Scene{{
+					Frames: []corev1alpha1.Frame{
+						{ID: "a", Name: "a", Action: helloWorldAction()},
+						{ID: "b", Story: &build},
+						{ID: "c", Story: &deploy},
+					},
+				}},
+			}, {
+				Name: build,
+				Provision: corev1alpha1.Provision{
+					Resources: []runtime.RawExtension{configMapResource("cache")},
+				},
+				Scenes: []corev1alpha1.Scene{{
+					Frames: []corev1alpha1.Frame{{ID: "d", Name: "d", Action: helloWorldAction()}},
+				}},
+			}, {
+				Name: deploy,
+				Scenes: []corev1alpha1.Scene{{
+					Frames: []corev1alpha1.Frame{{ID: "e", Name: "e", Action: helloWorldAction()}},
+				}},
+			}},
+		},
+	}
+
+	for _, test := range []struct {
+		screenplay string
+		frameID    string
+		want       []string
+	}{
+		{"main", "a", []string{"settings-test"}},
+		{build, "d", []string{"settings-test", "cache-test"}},
+		{deploy, "e", []string{"settings-test"}},
+	} {
+		frameID, want := test.frameID, test.want
+		job, err := generateActionJob(play, test.screenplay, frameID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		action := job.Spec
+		var configMaps, volumes []string
+		for _, envFrom := range action.Template.Spec.Containers[0].EnvFrom {
+			configMaps = append(configMaps, envFrom.ConfigMapRef.Name)
+		}
+		for _, volume := range action.Template.Spec.Volumes {
+			volumes = append(volumes, volume.Name)
+		}
+		if !reflect.DeepEqual(configMaps, want) {
+			t.Errorf("Want ConfigMaps %v exposed to frame %s, got %v", want, frameID, configMaps)
+		}
+		if len(volumes) != len(want) || (len(volumes) > 1 && volumes[0] == volumes[1]) {
+			t.Errorf("Want a volume for every ConfigMap exposed to frame %s, got %v", frameID, volumes)
+		}
+	}
+}
+
+func TestNextWithFailedStory(t *testing.T) {
+	story := "build"
+	play := &corev1alpha1.Play{
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{{
+					Name: "first-scene",
+					Frames: []corev1alpha1.Frame{{
+						ID:    "a",
+						Name:  "build",
+						Story: &story,
+					}},
+				}, {
+					Name: "second-scene",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "b",
+						Name:   "second-hello-a",
+						Action: helloWorldAction(),
+					}},
+				}},
+			}, {
+				Name: story,
+				Scenes: []corev1alpha1.Scene{{
+					Name: "build-scene",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "c",
+						Name:   "build-hello-a",
+						Action: helloWorldAction(),
+					}},
+				}},
+			}},
+		},
+	}
+
+	flow := NewFlow(&scheduler.DummyScheduler{Play: play, Result: corev1alpha1.FrameStateFailed})
+	flow.Next(play)
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"a": &failed,
+		"b": nil,
+		"c": &failed,
+	})
+
+	err := flow.Next(play)
+	if !IsPlayEndedErorr(err) {
+		t.Errorf("Play should have ended")
+	}
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"b": &skipped,
+	})
+}
+
+func TestNextWithRecursiveStory(t *testing.T) {
+	story := "main"
+	play := &corev1alpha1.Play{
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{{
+					Name: "first-scene",
+					Frames: []corev1alpha1.Frame{{
+						ID:    "a",
+						Name:  "recursion",
+						Story: &story,
+					}},
+				}},
+			}},
+		},
+	}
+
+	flow := NewFlow(&scheduler.DummyScheduler{Play: play})
+	if err := flow.Next(play); MessageForError(err) != InvalidSpec {
+		t.Errorf("Recursive story should not be played, got %v", err)
+	}
+}
+
+func TestNextWithDuplicateStory(t *testing.T) {
+	story := "build"
+	play := &corev1alpha1.Play{
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{{
+					Name: "build",
+					Frames: []corev1alpha1.Frame{{
+						ID:    "a",
+						Name:  "build",
+						Story: &story,
+					}},
+				}, {
+					Name: "rebuild",
+					Frames: []corev1alpha1.Frame{{
+						ID:    "b",
+						Name:  "rebuild",
+						Story: &story,
+					}},
+				}},
+			}, {
+				Name: story,
+				Scenes: []corev1alpha1.Scene{{
+					Name: "build",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "c",
+						Name:   "build",
+						Action: helloWorldAction(),
+					}},
+				}},
+			}},
+		},
+	}
+
+	flow := NewFlow(&scheduler.DummyScheduler{Play: play})
+	if err := flow.Next(play); MessageForError(err) != InvalidSpec {
+		t.Errorf("Story referenced by multiple frames should not be played, got %v", err)
+	}
+}
+
+func TestNextWithWhen(t *testing.T) {
+	play := &corev1alpha1.Play{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+			Annotations: map[string]string{
+				"example.com/deploy": "true",
+			},
+		},
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Provision: corev1alpha1.Provision{
+					Resources: []runtime.RawExtension{{
+						Raw: []byte(`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "event-data"}, "data": {"branch": "develop"}}`),
+					}},
+				},
+				Scenes: []corev1alpha1.Scene{{
+					Name: "test",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "a",
+						Name:   "test",
+						Action: helloWorldAction(),
+					}},
+				}, {
+					Name: "deploy",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "b",
+						Name:   "deploy-main",
+						Action: helloWorldAction(),
+						When:   `event.branch == "main"`,
+					}, {
+						ID:     "c",
+						Name:   "deploy-tested",
+						Action: helloWorldAction(),
+						When:   `frames.test.status == "succeeded" && annotations["example.com/deploy"] == "true"`,
+					}, {
+						ID:     "d",
+						Name:   "report-failure",
+						Action: helloWorldAction(),
+						When:   `frames.test.status == "failed"`,
+					}},
+				}},
+			}},
+		},
+	}
+
+	flow := NewFlow(&scheduler.DummyScheduler{Play: play})
+	flow.Next(play)
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"a": &success,
+		"b": &skipped,
+		"c": &success,
+		"d": &skipped,
+	})
+
+	err := flow.Next(play)
+	if !IsPlayEndedErorr(err) {
+		t.Errorf("Play should have ended")
+	}
+	if play.Status.Failed() {
+		t.Errorf("Skipped frames shouldn't fail the play")
+	}
+}
+
+func TestNextWithInvalidWhen(t *testing.T) {
+	play := &corev1alpha1.Play{
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{{
+					Name: "test",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "a",
+						Name:   "test",
+						Action: helloWorldAction(),
+						When:   `frames.missing.status == "failed"`,
+					}},
+				}},
+			}},
+		},
+	}
+
+	flow := NewFlow(&scheduler.DummyScheduler{Play: play})
+	if err := flow.Next(play); err != nil {
+		t.Fatalf("Frames with invalid when expression shouldn't stop the play: %s", err)
+	}
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"a": &failed,
+	})
+}
+
+func TestNextWithDAG(t *testing.T) {
+	play := &corev1alpha1.Play{
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				DAG:  true,
+				Scenes: []corev1alpha1.Scene{{
+					Name: "build",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "a",
+						Name:   "build-a",
+						Action: helloWorldAction(),
+					}, {
+						ID:     "b",
+						Name:   "build-b",
+						Action: helloWorldAction(),
+					}},
+				}, {
+					Name: "deploy",
+					Frames: []corev1alpha1.Frame{{
+						ID:        "c",
+						Name:      "deploy-a",
+						Action:    helloWorldAction(),
+						DependsOn: []string{"build-a"},
+					}, {
+						ID:        "d",
+						Name:      "deploy-b",
+						Action:    helloWorldAction(),
+						DependsOn: []string{"build-b"},
+					}, {
+						ID:        "e",
+						Name:      "verify",
+						Action:    helloWorldAction(),
+						DependsOn: []string{"deploy-a", "deploy-b"},
+					}},
+				}},
+			}},
+		},
+	}
+	running := corev1alpha1.FrameStateRunning
+
+	flow := NewFlow(&scheduler.DummyScheduler{Play: play, Result: running})
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"a": &running,
+		"b": &running,
+		"c": nil,
+		"d": nil,
+		"e": nil,
+	})
+
+	// Frame is played as soon as its dependencies succeed, even if its scene didn't finish
+	play.Status.SetFrameState("a", success)
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"b": &running,
+		"c": &running,
+		"d": nil,
+		"e": nil,
+	})
+
+	// Frames depending on failed frames are skipped
+	play.Status.SetFrameState("b", failed)
+	play.Status.SetFrameState("c", success)
+	flow.Next(play)
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"d": &skipped,
+		"e": &skipped,
+	})
+
+	err := flow.Next(play)
+	if !IsPlayEndedErorr(err) {
+		t.Errorf("Play should have ended")
+	}
+	if !play.Status.Failed() {
+		t.Errorf("Play should have failed")
+	}
+}
+
+func TestNextWithDependencyCycle(t *testing.T) {
+	play := &corev1alpha1.Play{
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				DAG:  true,
+				Scenes: []corev1alpha1.Scene{{
+					Name: "test",
+					Frames: []corev1alpha1.Frame{{
+						ID:        "a",
+						Name:      "a",
+						Action:    helloWorldAction(),
+						DependsOn: []string{"b"},
+					}, {
+						ID:        "b",
+						Name:      "b",
+						Action:    helloWorldAction(),
+						DependsOn: []string{"a"},
+					}},
+				}},
+			}},
+		},
+	}
+
+	flow := NewFlow(&scheduler.DummyScheduler{Play: play})
+	err := flow.Next(play)
+	if MessageForError(err) != InvalidSpec {
+		t.Errorf("Want %s error, got %v", InvalidSpec, err)
+	}
+}
+
+func TestNextAllowFailure(t *testing.T) {
+	play := &corev1alpha1.Play{
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{{
+					Name: "test",
+					Frames: []corev1alpha1.Frame{{
+						ID:           "a",
+						Name:         "lint",
+						Action:       helloWorldAction(),
+						AllowFailure: true,
+					}, {
+						ID:     "b",
+						Name:   "test",
+						Action: helloWorldAction(),
+					}},
+				}, {
+					Name: "release",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "c",
+						Name:   "release",
+						Action: helloWorldAction(),
+					}},
+				}},
+			}},
+		},
+	}
+	running := corev1alpha1.FrameStateRunning
+
+	flow := NewFlow(&scheduler.DummyScheduler{Play: play, Result: running})
+	flow.Next(play)
+	play.Status.SetFrameState("a", failed)
+	play.Status.SetFrameState("b", success)
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"a": &failed,
+		"b": &success,
+		"c": &running,
+	})
+
+	play.Status.SetFrameState("c", success)
+	err := flow.Next(play)
+	if !IsPlayEndedErorr(err) {
+		t.Errorf("Play should have ended")
+	}
+	if play.Status.Failed() {
+		t.Errorf("Allowed failure shouldn't fail the play")
+	}
+}
+
+func TestNextFailFast(t *testing.T) {
+	play := &corev1alpha1.Play{
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{{
+					Name:     "build",
+					FailFast: true,
+					Frames: []corev1alpha1.Frame{{
+						ID:     "a",
+						Name:   "build-a",
+						Action: helloWorldAction(),
+					}, {
+						ID:     "b",
+						Name:   "build-b",
+						Action: helloWorldAction(),
+					}, {
+						ID:     "c",
+						Name:   "build-c",
+						Action: helloWorldAction(),
+					}},
+				}, {
+					Name: "release",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "d",
+						Name:   "release",
+						Action: helloWorldAction(),
+					}},
+				}},
+			}},
+		},
+	}
+	running := corev1alpha1.FrameStateRunning
+	cancelled := corev1alpha1.FrameStateCancelled
+
+	flow := NewFlow(&scheduler.DummyScheduler{Play: play, Result: running})
+	flow.Next(play)
+	play.Status.SetFrameState("a", failed)
+	play.Status.SetFrameState("b", success)
+	err := flow.Next(play)
+	if !IsPlayEndedErorr(err) {
+		t.Errorf("Play should have ended")
+	}
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"a": &failed,
+		"b": &success,
+		"c": &cancelled,
+		"d": &skipped,
+	})
+	if !play.Status.Failed() {
+		t.Errorf("Play should have failed")
+	}
+}
+
+func TestNextSuspend(t *testing.T) {
+	play := &corev1alpha1.Play{
+		Spec: corev1alpha1.PlaySpec{
+			Suspend: true,
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{{
+					Name: "test",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "a",
+						Name:   "test",
+						Action: helloWorldAction(),
+					}},
+				}},
+			}},
+		},
+	}
+
+	flow := NewFlow(&scheduler.DummyScheduler{Play: play})
+	if err := flow.Next(play); err != nil {
+		t.Errorf("Suspended play shouldn't end: %v", err)
+	}
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"a": nil,
+	})
+
+	play.Spec.Suspend = false
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"a": &success,
+	})
+}
+
+func TestNextCancel(t *testing.T) {
+	play := &corev1alpha1.Play{
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{{
+					Name: "deploy",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "a",
+						Name:   "deploy",
+						Action: helloWorldAction(),
+					}},
+				}, {
+					Name: "verify",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "b",
+						Name:   "verify",
+						Action: helloWorldAction(),
+					}},
+				}},
+				Credits: &corev1alpha1.Credits{
+					Closing: []corev1alpha1.Frame{{
+						ID:     "c",
+						Name:   "cleanup",
+						Action: helloWorldAction(),
+					}},
+				},
+			}},
+		},
+	}
+	running := corev1alpha1.FrameStateRunning
+	cancelled := corev1alpha1.FrameStateCancelled
+
+	flow := NewFlow(&scheduler.DummyScheduler{Play: play, Result: running})
+	flow.Next(play)
+	play.Spec.Cancel = true
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"a": &cancelled,
+		"b": &cancelled,
+		"c": &running,
+	})
+
+	closing := play.Screenplay("main").Credits.Closing[0]
+	if env := closing.Action.Template.Spec.Containers[0].Env[0]; env.Name != kuberikScreenplayResultEnv || env.Value != kuberikScreenplayResultValueCancel {
+		t.Errorf("Expected to find %s env with status %s", kuberikScreenplayResultEnv, kuberikScreenplayResultValueCancel)
+	}
+
+	play.Status.SetFrameState("c", success)
+	if err := flow.Next(play); !IsPlayEndedErorr(err) {
+		t.Errorf("Play should have ended")
+	}
+	if play.Status.Failed() {
+		t.Errorf("Cancelled play shouldn't fail")
+	}
+}
+
+func TestNextCancelStory(t *testing.T) {
+	story := "deploy"
+	play := &corev1alpha1.Play{
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{{
+					Name: "deploy",
+					Frames: []corev1alpha1.Frame{{
+						ID:    "a",
+						Name:  "deploy",
+						Story: &story,
+					}},
+				}},
+			}, {
+				Name: story,
+				Scenes: []corev1alpha1.Scene{{
+					Name: "rollout",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "b",
+						Name:   "rollout",
+						Action: helloWorldAction(),
+					}},
+				}, {
+					Name: "verify",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "c",
+						Name:   "verify",
+						Action: helloWorldAction(),
+					}},
+				}},
+				Credits: &corev1alpha1.Credits{
+					Closing: []corev1alpha1.Frame{{
+						ID:     "d",
+						Name:   "cleanup",
+						Action: helloWorldAction(),
+					}},
+				},
+			}},
+		},
+	}
+	running := corev1alpha1.FrameStateRunning
+	cancelled := corev1alpha1.FrameStateCancelled
+
+	flow := NewFlow(&scheduler.DummyScheduler{Play: play, Result: running})
+	flow.Next(play)
+	play.Spec.Cancel = true
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"a": &running,
+		"b": &cancelled,
+		"c": &cancelled,
+		"d": &running,
+	})
+
+	play.Status.SetFrameState("d", success)
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"a": &cancelled,
+	})
+	if err := flow.Next(play); !IsPlayEndedErorr(err) {
+		t.Errorf("Play should have ended")
+	}
+	if play.Status.Failed() {
+		t.Errorf("Cancelled play shouldn't fail")
+	}
+}
+
+func TestNextInvalidProvisionedResources(t *testing.T) {
+	play := &corev1alpha1.Play{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Provision: corev1alpha1.Provision{
+					Resources: []runtime.RawExtension{{
+						Raw: []byte(`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "duplicate"}}`),
+					}, {
+						Raw: []byte(`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "duplicate"}}`),
+					}},
+				},
+			}},
+		},
+	}
+
+	flow := NewFlow(&scheduler.DummyScheduler{Play: play})
+	err := flow.Next(play)
+	if MessageForError(err) != InvalidSpec {
+		t.Errorf("Want %s error, got %v", InvalidSpec, err)
+	}
+}
+
+func TestNextProvisionFailed(t *testing.T) {
+	play := &corev1alpha1.Play{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Provision: corev1alpha1.Provision{
+					Resources: []runtime.RawExtension{{
+						Raw: []byte(`{"apiVersion": "v1", "kind": "PersistentVolumeClaim", "metadata": {"name": "claim"}}`),
+					}},
+				},
+			}},
+		},
+	}
+
+	flow := NewFlow(&scheduler.ShellScheduler{})
+	err := flow.Next(play)
+	if MessageForError(err) != ProvisionFailed {
+		t.Errorf("Want %s error, got %v", ProvisionFailed, err)
+	}
+	if IsPlayFailedError(err) {
+		t.Errorf("Provisioning failure shouldn't stop the play")
+	}
+}
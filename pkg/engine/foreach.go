@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+
+	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// frameItemVar is name of the environment variable holding the item of a frame played for each item of an output
+const frameItemVar = "FRAME_ITEM"
+
+// expandForEach creates a copy of every frame iterating over output of a frame for each item of the output.
+// Frames are expanded once the frame producing the output succeeds, until then they stay pending.
+func expandForEach(play *corev1alpha1.Play) {
+	for k := range play.Spec.Screenplays {
+		screenplay := &play.Spec.Screenplays[k]
+		copies := make(map[string][]string)
+		for si := range screenplay.Scenes {
+			var frames []corev1alpha1.Frame
+			for _, f := range screenplay.Scenes[si].Frames {
+				if f.ForEach == "" || f.Action == nil {
+					frames = append(frames, f)
+					continue
+				}
+				items, _, err := forEachItems(play, screenplay, f)
+				if err != nil || items == nil {
+					frames = append(frames, f)
+					continue
+				}
+				// Frames depending on a frame without items don't wait for it
+				copies[f.Name] = nil
+				for _, fc := range expandItems(f, items) {
+					frames = append(frames, fc)
+					copies[f.Name] = append(copies[f.Name], fc.Name)
+				}
+			}
+			screenplay.Scenes[si].Frames = frames
+		}
+		dependOnCopies(screenplay, copies)
+	}
+}
+
+// forEachItems returns items of the output which the frame iterates over together with the state
+// of the frame producing it. Items are only returned once the frame succeeds.
+func forEachItems(play *corev1alpha1.Play, screenplay *corev1alpha1.Screenplay, frame corev1alpha1.Frame) ([]string, corev1alpha1.FrameState, error) {
+	name, err := frame.ForEachFrame()
+	if err != nil {
+		return nil, "", err
+	}
+	var producer *corev1alpha1.Frame
+	for _, f := range sceneFrames(screenplay) {
+		if f.Name == name {
+			producer = &f
+			break
+		}
+	}
+	if producer == nil {
+		return nil, "", fmt.Errorf("frame '%s' iterates over output of frame '%s' which doesn't exist", frame.Name, name)
+	}
+
+	state := play.Status.FrameState(producer.ID)
+	if state != corev1alpha1.FrameStateSucceeded {
+		return nil, state, nil
+	}
+	var values []interface{}
+	if err := json.Unmarshal([]byte(play.Status.Frames[producer.ID].TerminationMessage), &values); err != nil {
+		return nil, state, fmt.Errorf("output of frame '%s' isn't a JSON list: %s", name, err)
+	}
+	items := make([]string, 0, len(values))
+	for _, value := range values {
+		if s, ok := value.(string); ok {
+			items = append(items, s)
+			continue
+		}
+		item, err := json.Marshal(value)
+		if err != nil {
+			return nil, state, err
+		}
+		items = append(items, string(item))
+	}
+	return items, state, nil
+}
+
+// expandItems creates a copy of the frame for every item. Copies are named after their items
+// and get the item injected into their containers as an environment variable.
+func expandItems(frame corev1alpha1.Frame, items []string) (frames []corev1alpha1.Frame) {
+	names := make(map[string]bool)
+	for i, item := range items {
+		fc := frame.DeepCopy()
+		fc.ForEach = ""
+		fc.ID = fmt.Sprintf("%s-%v", fc.ID, i)
+		fc.Name = fmt.Sprintf("%s-%s", frame.Name, nameSuffix(item))
+		if names[fc.Name] {
+			fc.Name = fmt.Sprintf("%s-%v", frame.Name, i)
+		}
+		names[fc.Name] = true
+
+		for ci := range fc.Action.Template.Spec.Containers {
+			fc.Action.Template.Spec.Containers[ci].Env = append(fc.Action.Template.Spec.Containers[ci].Env, corev1.EnvVar{
+				Name:  frameItemVar,
+				Value: item,
+			}, corev1.EnvVar{
+				Name:  frameCopyIndexVar,
+				Value: fmt.Sprintf("%v", i),
+			})
+		}
+		frames = append(frames, *fc)
+	}
+	return
+}
+
+// finishForEach skips or fails a frame which can't be expanded because the frame producing
+// its items didn't succeed or produced an invalid output
+func finishForEach(play *corev1alpha1.Play, screenplay, scene string, frame corev1alpha1.Frame) {
+	_, state, err := forEachItems(play, play.Screenplay(screenplay), frame)
+	switch {
+	case err != nil:
+		finishPendingFrames(play, screenplay, scene, []corev1alpha1.Frame{frame}, corev1alpha1.FrameStateFailed, "", err.Error())
+	case state.Finished():
+		skipFrames(play, screenplay, scene, []corev1alpha1.Frame{frame}, fmt.Sprintf("Skipped because frame producing items of '%s' didn't succeed", frame.ForEach))
+	}
+}
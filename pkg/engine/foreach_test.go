@@ -0,0 +1,188 @@
+package engine
+
+import (
+	"testing"
+
+	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
+	"github.com/kuberik/engine/pkg/engine/scheduler"
+)
+
+func setOutput(play *corev1alpha1.Play, frameID, output string) {
+	status := play.Status.Frames[frameID]
+	status.TerminationMessage = output
+	play.Status.SetFrameStatus(frameID, status)
+}
+
+func TestNextForEach(t *testing.T) {
+	play := &corev1alpha1.Play{
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{{
+					Name: "discover",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "a",
+						Name:   "discover",
+						Action: helloWorldAction(),
+					}},
+				}, {
+					Name: "build",
+					Frames: []corev1alpha1.Frame{{
+						ID:      "b",
+						Name:    "build",
+						Action:  helloWorldAction(),
+						ForEach: "frames.discover.output",
+					}},
+				}},
+			}},
+		},
+	}
+	flow := NewFlow(&scheduler.DummyScheduler{Play: play})
+	flow.Next(play)
+	setOutput(play, "a", `["api", "web/frontend", {"name": "worker"}]`)
+
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"a":   &success,
+		"b-0": &success,
+		"b-1": &success,
+		"b-2": &success,
+	})
+	frame := play.Frame("b-1")
+	if frame == nil || frame.Name != "build-web-frontend" {
+		t.Fatalf("Expected copy of the frame named build-web-frontend, got %v", frame)
+	}
+	env := make(map[string]string)
+	for _, e := range play.Frame("b-2").Action.Template.Spec.Containers[0].Env {
+		env[e.Name] = e.Value
+	}
+	if want := `{"name":"worker"}`; env[frameItemVar] != want {
+		t.Errorf("Item want %s, got %s", want, env[frameItemVar])
+	}
+}
+
+func TestNextForEachProducerFailed(t *testing.T) {
+	play := &corev1alpha1.Play{
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{{
+					Name: "discover",
+					Frames: []corev1alpha1.Frame{{
+						ID:           "a",
+						Name:         "discover",
+						Action:       helloWorldAction(),
+						AllowFailure: true,
+					}},
+				}, {
+					Name: "build",
+					Frames: []corev1alpha1.Frame{{
+						ID:      "b",
+						Name:    "build",
+						Action:  helloWorldAction(),
+						ForEach: "frames.discover.output",
+					}},
+				}},
+			}},
+		},
+	}
+	flow := NewFlow(&scheduler.DummyScheduler{Play: play, Result: corev1alpha1.FrameStateFailed})
+	flow.Next(play)
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"a": &failed,
+		"b": &skipped,
+	})
+}
+
+func TestNextForEachInvalidOutput(t *testing.T) {
+	play := &corev1alpha1.Play{
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{{
+					Name: "discover",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "a",
+						Name:   "discover",
+						Action: helloWorldAction(),
+					}},
+				}, {
+					Name: "build",
+					Frames: []corev1alpha1.Frame{{
+						ID:      "b",
+						Name:    "build",
+						Action:  helloWorldAction(),
+						ForEach: "frames.discover.output",
+					}},
+				}},
+			}},
+		},
+	}
+	flow := NewFlow(&scheduler.DummyScheduler{Play: play})
+	flow.Next(play)
+	setOutput(play, "a", "done")
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"a": &success,
+		"b": &failed,
+	})
+}
+
+func TestNextForEachInDAG(t *testing.T) {
+	play := &corev1alpha1.Play{
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				DAG:  true,
+				Scenes: []corev1alpha1.Scene{{
+					Name: "discover",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "a",
+						Name:   "discover",
+						Action: helloWorldAction(),
+					}},
+				}, {
+					Name: "build",
+					Frames: []corev1alpha1.Frame{{
+						ID:      "b",
+						Name:    "build",
+						Action:  helloWorldAction(),
+						ForEach: "frames.discover.output",
+					}, {
+						ID:        "c",
+						Name:      "release",
+						Action:    helloWorldAction(),
+						DependsOn: []string{"build"},
+					}},
+				}},
+			}},
+		},
+	}
+	dummy := &scheduler.DummyScheduler{Play: play, Result: corev1alpha1.FrameStateRunning}
+	flow := NewFlow(dummy)
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"a": nil,
+		"b": nil,
+		"c": nil,
+	})
+
+	play.Status.SetFrameState("a", success)
+	setOutput(play, "a", `["api"]`)
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"b-0": nil,
+		"c":   nil,
+	})
+	if state := play.Status.FrameState("b-0"); state != corev1alpha1.FrameStateRunning {
+		t.Errorf("Copy of the frame want state %s, got %s", corev1alpha1.FrameStateRunning, state)
+	}
+
+	dummy.Result = corev1alpha1.FrameStateSucceeded
+	play.Status.SetFrameState("b-0", success)
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"c": &success,
+	})
+}
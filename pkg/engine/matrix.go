@@ -0,0 +1,150 @@
+package engine
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+
+	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// frameMatrixVarPrefix prefixes names of environment variables which hold values of matrix axes
+const frameMatrixVarPrefix = "FRAME_MATRIX_"
+
+// expandMatrix creates a copy of the frame for every combination of the matrix. Every value of
+// the combination is injected into containers of the copy as an environment variable.
+func expandMatrix(frame corev1alpha1.Frame) (frames []corev1alpha1.Frame) {
+	names := make(map[string]bool)
+	for i, combination := range matrixCombinations(frame.Matrix) {
+		fc := frame.DeepCopy()
+		fc.Matrix = nil
+		fc.ID = fmt.Sprintf("%s-%v", fc.ID, i)
+		fc.Name = matrixFrameName(frame.Name, combination)
+		// Values like 1.14 and 1-14 end up with the same name
+		if names[fc.Name] {
+			fc.Name = fmt.Sprintf("%s-%v", fc.Name, i)
+		}
+		names[fc.Name] = true
+
+		axes := combinationAxes(combination)
+		for ci := range fc.Action.Template.Spec.Containers {
+			for _, axis := range axes {
+				fc.Action.Template.Spec.Containers[ci].Env = append(fc.Action.Template.Spec.Containers[ci].Env, corev1.EnvVar{
+					Name:  matrixEnvVar(axis),
+					Value: combination[axis],
+				})
+			}
+		}
+		frames = append(frames, *fc)
+	}
+	return
+}
+
+// matrixCombinations returns the cartesian product of values of the axes without the excluded
+// combinations, followed by the included combinations
+func matrixCombinations(matrix *corev1alpha1.Matrix) (combinations []map[string]string) {
+	var product []map[string]string
+	if len(matrix.Axes) > 0 {
+		product = []map[string]string{{}}
+	}
+	for _, axis := range sortedAxes(matrix.Axes) {
+		var next []map[string]string
+		for _, combination := range product {
+			for _, value := range matrix.Axes[axis] {
+				c := map[string]string{axis: value}
+				for k, v := range combination {
+					c[k] = v
+				}
+				next = append(next, c)
+			}
+		}
+		product = next
+	}
+
+product:
+	for _, combination := range product {
+		for _, exclude := range matrix.Exclude {
+			if matrixMatches(combination, exclude) {
+				continue product
+			}
+		}
+		combinations = append(combinations, combination)
+	}
+
+include:
+	for _, include := range matrix.Include {
+		for _, combination := range combinations {
+			if reflect.DeepEqual(combination, include) {
+				continue include
+			}
+		}
+		combinations = append(combinations, include)
+	}
+	return
+}
+
+// matrixMatches checks if a combination has all the given values
+func matrixMatches(combination, values map[string]string) bool {
+	for axis, value := range values {
+		if combination[axis] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// sortedAxes returns names of the axes in alphabetical order
+func sortedAxes(axes map[string][]string) (names []string) {
+	for axis := range axes {
+		names = append(names, axis)
+	}
+	sort.Strings(names)
+	return
+}
+
+// combinationAxes returns names of the axes of a combination in alphabetical order
+func combinationAxes(combination map[string]string) (names []string) {
+	for axis := range combination {
+		names = append(names, axis)
+	}
+	sort.Strings(names)
+	return
+}
+
+// matrixFrameName appends values of the combination to the name of the frame
+func matrixFrameName(name string, combination map[string]string) string {
+	parts := []string{name}
+	for _, axis := range combinationAxes(combination) {
+		parts = append(parts, nameSuffix(combination[axis]))
+	}
+	return strings.Join(parts, "-")
+}
+
+// nameSuffix replaces characters of a value which can't be used in names of Jobs
+func nameSuffix(value string) string {
+	return strings.Map(func(r rune) rune {
+		r = unicode.ToLower(r)
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '-'
+	}, value)
+}
+
+// matrixEnvVar returns name of the environment variable for an axis, e.g. FRAME_MATRIX_GO_VERSION for goVersion
+func matrixEnvVar(axis string) string {
+	var b strings.Builder
+	b.WriteString(frameMatrixVarPrefix)
+	var previous rune
+	for _, r := range axis {
+		if unicode.IsUpper(r) && (unicode.IsLower(previous) || unicode.IsDigit(previous)) {
+			b.WriteRune('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+		previous = r
+	}
+	return b.String()
+}
@@ -0,0 +1,130 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+
+	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
+	"github.com/kuberik/engine/pkg/engine/scheduler"
+)
+
+func TestMatrixCombinations(t *testing.T) {
+	matrix := &corev1alpha1.Matrix{
+		Axes: map[string][]string{
+			"goVersion": {"1.14", "1.15"},
+			"os":        {"alpine", "debian"},
+		},
+		Exclude: []map[string]string{
+			{"goVersion": "1.14", "os": "debian"},
+		},
+		Include: []map[string]string{
+			{"goVersion": "1.16", "os": "alpine"},
+			{"goVersion": "1.15", "os": "alpine"},
+		},
+	}
+	want := []map[string]string{
+		{"goVersion": "1.14", "os": "alpine"},
+		{"goVersion": "1.15", "os": "alpine"},
+		{"goVersion": "1.15", "os": "debian"},
+		{"goVersion": "1.16", "os": "alpine"},
+	}
+	if got := matrixCombinations(matrix); !reflect.DeepEqual(got, want) {
+		t.Errorf("Combinations want %v, got %v", want, got)
+	}
+}
+
+func TestMatrixEnvVar(t *testing.T) {
+	for axis, want := range map[string]string{
+		"os":          "FRAME_MATRIX_OS",
+		"goVersion":   "FRAME_MATRIX_GO_VERSION",
+		"k8s_VERSION": "FRAME_MATRIX_K8S_VERSION",
+	} {
+		if got := matrixEnvVar(axis); got != want {
+			t.Errorf("Environment variable of %s want %s, got %s", axis, want, got)
+		}
+	}
+}
+
+func TestExpandMatrix(t *testing.T) {
+	spec := &corev1alpha1.PlaySpec{
+		Screenplays: []corev1alpha1.Screenplay{{
+			Name: "main",
+			DAG:  true,
+			Scenes: []corev1alpha1.Scene{{
+				Name: "test",
+				Frames: []corev1alpha1.Frame{{
+					ID:     "a",
+					Name:   "test",
+					Action: helloWorldAction(),
+					Matrix: &corev1alpha1.Matrix{
+						Axes: map[string][]string{
+							"goVersion": {"1.14", "1.15"},
+							"os":        {"alpine"},
+						},
+					},
+				}, {
+					ID:        "b",
+					Name:      "release",
+					Action:    helloWorldAction(),
+					DependsOn: []string{"test"},
+				}},
+			}},
+		}},
+	}
+	expandCopies(spec)
+
+	frames := spec.Screenplays[0].Scenes[0].Frames
+	var names []string
+	for _, frame := range frames {
+		names = append(names, frame.Name)
+	}
+	if want := []string{"test-1-14-alpine", "test-1-15-alpine", "release"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("Frames want %v, got %v", want, names)
+	}
+	if frames[1].ID != "a-1" {
+		t.Errorf("Frame ID want a-1, got %s", frames[1].ID)
+	}
+	env := make(map[string]string)
+	for _, e := range frames[1].Action.Template.Spec.Containers[0].Env {
+		env[e.Name] = e.Value
+	}
+	if env["FRAME_MATRIX_GO_VERSION"] != "1.15" || env["FRAME_MATRIX_OS"] != "alpine" {
+		t.Errorf("Values of the combination aren't injected: %v", env)
+	}
+	if want := []string{"test-1-14-alpine", "test-1-15-alpine"}; !reflect.DeepEqual(frames[2].DependsOn, want) {
+		t.Errorf("Dependencies want %v, got %v", want, frames[2].DependsOn)
+	}
+}
+
+func TestNextWithEmptyMatrix(t *testing.T) {
+	play := &corev1alpha1.Play{
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				DAG:  true,
+				Scenes: []corev1alpha1.Scene{{
+					Name: "test",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "a",
+						Name:   "test",
+						Action: helloWorldAction(),
+						Matrix: &corev1alpha1.Matrix{
+							Axes:    map[string][]string{"os": {"alpine"}},
+							Exclude: []map[string]string{{"os": "alpine"}},
+						},
+					}, {
+						ID:        "b",
+						Name:      "release",
+						Action:    helloWorldAction(),
+						DependsOn: []string{"test"},
+					}},
+				}},
+			}},
+		},
+	}
+	flow := NewFlow(&scheduler.DummyScheduler{Play: play})
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"b": &success,
+	})
+}
@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
+)
+
+// outputReference matches references to outputs of frames, e.g. $(frames.build.outputs.digest)
+var outputReference = regexp.MustCompile(`\$\(frames\.([a-zA-Z0-9_-]+)\.outputs\.([a-zA-Z0-9_-]+)\)`)
+
+// recordOutputs stores outputs of frames which succeeded in their statuses.
+// Frames which didn't publish all of their outputs fail.
+func recordOutputs(play *corev1alpha1.Play) {
+	for _, frame := range play.AllFrames() {
+		status, ok := play.Status.Frames[frame.ID]
+		if !ok || len(frame.Outputs) == 0 || status.State != corev1alpha1.FrameStateSucceeded || status.Outputs != nil {
+			continue
+		}
+
+		values := parseOutputs(status.TerminationMessage)
+		status.Outputs = make(map[string]string)
+		var missing []string
+		for _, output := range frame.Outputs {
+			value, ok := values[output.Name]
+			if !ok {
+				missing = append(missing, output.Name)
+				continue
+			}
+			status.Outputs[output.Name] = value
+		}
+		if len(missing) > 0 {
+			status.State = corev1alpha1.FrameStateFailed
+			status.Reason = corev1alpha1.FrameReasonMissingOutputs
+			status.Message = fmt.Sprintf("Frame didn't publish outputs: %s", strings.Join(missing, ", "))
+		}
+		play.Status.SetFrameStatus(frame.ID, status)
+	}
+}
+
+// parseOutputs parses a termination message containing either a JSON object or key=value lines
+func parseOutputs(message string) map[string]string {
+	outputs := make(map[string]string)
+	object := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(message), &object); err == nil {
+		for key, value := range object {
+			if s, ok := value.(string); ok {
+				outputs[key] = s
+			} else if encoded, err := json.Marshal(value); err == nil {
+				outputs[key] = string(encoded)
+			}
+		}
+		return outputs
+	}
+
+	for _, line := range strings.Split(message, "\n") {
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) == 2 {
+			outputs[strings.TrimSpace(parts[0])] = parts[1]
+		}
+	}
+	return outputs
+}
+
+// outputsReady checks if frames whose outputs are referenced by the frame finished. Error is
+// returned if any of them finished without the referenced output.
+func outputsReady(play *corev1alpha1.Play, screenplay string, frame corev1alpha1.Frame) (bool, error) {
+	raw, err := json.Marshal(frame.Action)
+	if err != nil {
+		return false, err
+	}
+	frames := screenplayFramesByName(play, screenplay)
+	for _, match := range outputReference.FindAllStringSubmatch(string(raw), -1) {
+		producer, ok := frames[match[1]]
+		if !ok {
+			return false, fmt.Errorf("frame '%s' references outputs of frame '%s' which doesn't exist", frame.Name, match[1])
+		}
+		if !declaresOutput(producer, match[2]) {
+			return false, fmt.Errorf("frame '%s' references output '%s' which frame '%s' doesn't declare", frame.Name, match[2], match[1])
+		}
+		status := play.Status.Frames[producer.ID]
+		state := play.Status.FrameState(producer.ID)
+		// Outputs of frames which just succeeded are recorded on the next step of the flow
+		if !state.Finished() || (state == corev1alpha1.FrameStateSucceeded && status.Outputs == nil) {
+			return false, nil
+		}
+		if _, ok := status.Outputs[match[2]]; !ok {
+			return false, fmt.Errorf("frame '%s' references output '%s' which frame '%s' didn't publish", frame.Name, match[2], match[1])
+		}
+	}
+	return true, nil
+}
+
+func declaresOutput(frame corev1alpha1.Frame, name string) bool {
+	for _, output := range frame.Outputs {
+		if output.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// substituteOutputs replaces references to outputs of frames in an action with their values
+func substituteOutputs(play *corev1alpha1.Play, screenplay string, action corev1alpha1.Action) (corev1alpha1.Action, error) {
+	raw, err := json.Marshal(action)
+	if err != nil {
+		return action, err
+	}
+	frames := screenplayFramesByName(play, screenplay)
+	var missing []string
+	substituted := outputReference.ReplaceAllStringFunc(string(raw), func(reference string) string {
+		match := outputReference.FindStringSubmatch(reference)
+		value, ok := play.Status.Frames[frames[match[1]].ID].Outputs[match[2]]
+		if !ok {
+			missing = append(missing, reference)
+			return reference
+		}
+		// Values are substituted into JSON strings, so they need to be escaped
+		escaped, _ := json.Marshal(value)
+		return string(escaped[1 : len(escaped)-1])
+	})
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return action, fmt.Errorf("outputs %s aren't available", strings.Join(missing, ", "))
+	}
+
+	result := corev1alpha1.Action{}
+	if err := json.Unmarshal([]byte(substituted), &result); err != nil {
+		return action, err
+	}
+	return result, nil
+}
+
+// screenplayFramesByName maps names of frames of a screenplay to the frames
+func screenplayFramesByName(play *corev1alpha1.Play, screenplay string) map[string]corev1alpha1.Frame {
+	frames := make(map[string]corev1alpha1.Frame)
+	for _, frame := range screenplayFrames(play.Screenplay(screenplay)) {
+		frames[frame.Name] = frame
+	}
+	return frames
+}
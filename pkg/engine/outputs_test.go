@@ -0,0 +1,280 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+
+	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
+	"github.com/kuberik/engine/pkg/engine/scheduler"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestParseOutputs(t *testing.T) {
+	for message, want := range map[string]map[string]string{
+		`{"digest": "sha256:abc", "replicas": 3}`: {"digest": "sha256:abc", "replicas": "3"},
+		"digest=sha256:abc\ntag=v1=rc1\n":         {"digest": "sha256:abc", "tag": "v1=rc1"},
+		"done":                                    {},
+	} {
+		if got := parseOutputs(message); !reflect.DeepEqual(got, want) {
+			t.Errorf("Outputs of '%s' want %v, got %v", message, want, got)
+		}
+	}
+}
+
+func TestNextWithOutputs(t *testing.T) {
+	action := helloWorldAction()
+	action.Template.Spec.Containers[0].Image = "registry.example.com/app@$(frames.build.outputs.digest)"
+	action.Template.Spec.Containers[0].Env = []corev1.EnvVar{{
+		Name:  "TAG",
+		Value: `$(frames.build.outputs.tag)`,
+	}}
+	play := &corev1alpha1.Play{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{{
+					Name: "build",
+					Frames: []corev1alpha1.Frame{{
+						ID:      "a",
+						Name:    "build",
+						Action:  helloWorldAction(),
+						Outputs: []corev1alpha1.Output{{Name: "digest"}, {Name: "tag"}},
+					}},
+				}, {
+					Name: "deploy",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "b",
+						Name:   "deploy",
+						Action: action,
+					}},
+				}},
+			}},
+		},
+	}
+	flow := NewFlow(&scheduler.DummyScheduler{Play: play})
+	flow.Next(play)
+	setOutput(play, "a", `{"digest": "sha256:abc", "tag": "v1 \"rc\""}`)
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"a": &success,
+		"b": &success,
+	})
+	if want := map[string]string{"digest": "sha256:abc", "tag": `v1 "rc"`}; !reflect.DeepEqual(play.Status.Frames["a"].Outputs, want) {
+		t.Errorf("Outputs want %v, got %v", want, play.Status.Frames["a"].Outputs)
+	}
+
+	job, err := generateActionJob(play, "main", "b")
+	if err != nil {
+		t.Fatalf("Failed to generate job: %s", err)
+	}
+	container := job.Spec.Template.Spec.Containers[0]
+	if want := "registry.example.com/app@sha256:abc"; container.Image != want {
+		t.Errorf("Image want %s, got %s", want, container.Image)
+	}
+	if want := `v1 "rc"`; container.Env[0].Value != want {
+		t.Errorf("Environment variable want %s, got %s", want, container.Env[0].Value)
+	}
+}
+
+func TestNextWithMissingOutputs(t *testing.T) {
+	action := helloWorldAction()
+	action.Template.Spec.Containers[0].Image = "registry.example.com/app@$(frames.build.outputs.digest)"
+	action.Template.Spec.Containers[0].Env = []corev1.EnvVar{{
+		Name:  "TAG",
+		Value: `$(frames.build.outputs.tag)`,
+	}}
+	play := &corev1alpha1.Play{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{{
+					Name: "build",
+					Frames: []corev1alpha1.Frame{{
+						ID:      "a",
+						Name:    "build",
+						Action:  helloWorldAction(),
+						Outputs: []corev1alpha1.Output{{Name: "digest"}, {Name: "tag"}},
+					}},
+				}, {
+					Name: "deploy",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "b",
+						Name:   "deploy",
+						Action: action,
+					}},
+				}},
+			}},
+		},
+	}
+	flow := NewFlow(&scheduler.DummyScheduler{Play: play})
+	flow.Next(play)
+	setOutput(play, "a", "digest=sha256:abc")
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"a": &failed,
+		"b": &skipped,
+	})
+	if reason := play.Status.Frames["a"].Reason; reason != corev1alpha1.FrameReasonMissingOutputs {
+		t.Errorf("Frame reason want %s, got %s", corev1alpha1.FrameReasonMissingOutputs, reason)
+	}
+}
+
+func TestNextWithOutputsOfSameScene(t *testing.T) {
+	action := helloWorldAction()
+	action.Template.Spec.Containers[0].Image = "registry.example.com/app@$(frames.build.outputs.digest)"
+	action.Template.Spec.Containers[0].Env = []corev1.EnvVar{{
+		Name:  "TAG",
+		Value: `$(frames.build.outputs.tag)`,
+	}}
+	play := &corev1alpha1.Play{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{{
+					Name: "build",
+					Frames: []corev1alpha1.Frame{{
+						ID:      "a",
+						Name:    "build",
+						Action:  helloWorldAction(),
+						Outputs: []corev1alpha1.Output{{Name: "digest"}, {Name: "tag"}},
+					}, {
+						ID:     "b",
+						Name:   "deploy",
+						Action: action,
+					}},
+				}},
+			}},
+		},
+	}
+	flow := NewFlow(&scheduler.DummyScheduler{Play: play})
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"a": &success,
+		"b": nil,
+	})
+	if state := play.Status.FrameState("b"); state != corev1alpha1.FrameStatePending {
+		t.Errorf("Frame waiting for outputs want state %s, got %s", corev1alpha1.FrameStatePending, state)
+	}
+
+	setOutput(play, "a", "digest=sha256:abc\ntag=v1")
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"b": &success,
+	})
+}
+
+func TestNextWithUndeclaredOutput(t *testing.T) {
+	action := helloWorldAction()
+	action.Template.Spec.Containers[0].Image = "registry.example.com/app@$(frames.build.outputs.digest)"
+	action.Template.Spec.Containers[0].Env = []corev1.EnvVar{{
+		Name:  "TAG",
+		Value: `$(frames.build.outputs.tag)`,
+	}}
+	play := &corev1alpha1.Play{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{{
+					Name: "build",
+					Frames: []corev1alpha1.Frame{{
+						ID:      "a",
+						Name:    "build",
+						Action:  helloWorldAction(),
+						Outputs: []corev1alpha1.Output{{Name: "digest"}},
+					}},
+				}, {
+					Name: "deploy",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "b",
+						Name:   "deploy",
+						Action: action,
+					}},
+				}},
+			}},
+		},
+	}
+	flow := NewFlow(&scheduler.DummyScheduler{Play: play})
+	flow.Next(play)
+	setOutput(play, "a", "digest=sha256:abc")
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"a": &success,
+		"b": &failed,
+	})
+}
+
+func TestGenerateJobWithOutputs(t *testing.T) {
+	action := helloWorldAction()
+	action.Template.Spec.Containers[0].Image = "registry.example.com/app@$(frames.build.outputs.digest)"
+	play := &corev1alpha1.Play{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: corev1alpha1.PlaySpec{
+			Vars: []corev1alpha1.Var{{
+				Name:      "BRANCH",
+				ValueFrom: &corev1alpha1.VarSource{EventField: "branch"},
+			}},
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Provision: corev1alpha1.Provision{
+					Resources: []runtime.RawExtension{{
+						Raw: []byte(`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "event-data"}, "data": {"branch": "$(frames.build.outputs.digest)"}}`),
+					}},
+				},
+				Scenes: []corev1alpha1.Scene{{
+					Name: "build",
+					Frames: []corev1alpha1.Frame{{
+						ID:      "a",
+						Name:    "build",
+						Action:  helloWorldAction(),
+						Outputs: []corev1alpha1.Output{{Name: "digest"}},
+					}},
+				}, {
+					Name: "deploy",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "b",
+						Name:   "deploy",
+						Action: action,
+					}},
+				}},
+			}},
+		},
+	}
+
+	// Outputs which weren't recorded make the spec of the Job invalid
+	if _, err := generateActionJob(play, "main", "b"); MessageForError(err) != InvalidSpec {
+		t.Errorf("Want %s error, got %v", InvalidSpec, err)
+	}
+
+	play.Status.SetFrameStatus("a", corev1alpha1.FrameStatus{
+		State:   corev1alpha1.FrameStateSucceeded,
+		Outputs: map[string]string{"digest": "sha256:abc"},
+	})
+	job, err := generateActionJob(play, "main", "b")
+	if err != nil {
+		t.Fatalf("Failed to generate job: %s", err)
+	}
+	container := job.Spec.Template.Spec.Containers[0]
+	if want := "registry.example.com/app@sha256:abc"; container.Image != want {
+		t.Errorf("Image want %s, got %s", want, container.Image)
+	}
+	// Values injected from Event data aren't substituted
+	if want := "$(frames.build.outputs.digest)"; container.Env[0].Name != "BRANCH" || container.Env[0].Value != want {
+		t.Errorf("Event data want %s, got %v", want, container.Env[0])
+	}
+}
@@ -0,0 +1,35 @@
+package engine
+
+import (
+	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
+)
+
+// Rerun prepares a finished Play to be played again from the point of failure.
+// Results of frames which succeeded are kept, while the other frames are played again
+// together with closing credits of the screenplays which are played again.
+func Rerun(play *corev1alpha1.Play) {
+	for frameID, status := range play.Status.Frames {
+		allowedFailure := status.State == corev1alpha1.FrameStateFailed && status.FailureAllowed
+		if status.State != corev1alpha1.FrameStateSucceeded && !allowedFailure {
+			delete(play.Status.Frames, frameID)
+		}
+	}
+
+	rerunClosingCredits(play, mainScreenplayName)
+	for _, frame := range play.AllFrames() {
+		if frame.Story != nil && play.Status.FrameState(frame.ID) == corev1alpha1.FrameStatePending {
+			rerunClosingCredits(play, *frame.Story)
+		}
+	}
+	play.Status.Reruns++
+}
+
+func rerunClosingCredits(play *corev1alpha1.Play, name string) {
+	screenplay := play.Screenplay(name)
+	if screenplay == nil || screenplay.Credits == nil {
+		return
+	}
+	for _, frame := range screenplay.Credits.Closing {
+		delete(play.Status.Frames, frame.ID)
+	}
+}
@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"testing"
+
+	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRerun(t *testing.T) {
+	play := &corev1alpha1.Play{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{{
+					Name: "build",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "a",
+						Name:   "build",
+						Action: helloWorldAction(),
+					}, {
+						ID:     "b",
+						Name:   "lint",
+						Action: helloWorldAction(),
+					}},
+				}, {
+					Name: "deploy",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "c",
+						Name:   "deploy",
+						Action: helloWorldAction(),
+					}, {
+						ID:     "d",
+						Name:   "verify",
+						Action: helloWorldAction(),
+					}},
+				}},
+				Credits: &corev1alpha1.Credits{
+					Closing: []corev1alpha1.Frame{{
+						ID:     "e",
+						Name:   "cleanup",
+						Action: helloWorldAction(),
+					}},
+				},
+			}},
+		},
+		Status: corev1alpha1.PlayStatus{
+			Frames: map[string]corev1alpha1.FrameStatus{
+				"a": {State: corev1alpha1.FrameStateSucceeded},
+				"b": {State: corev1alpha1.FrameStateFailed, FailureAllowed: true},
+				"c": {State: corev1alpha1.FrameStateFailed},
+				"d": {State: corev1alpha1.FrameStateCancelled},
+				"e": {State: corev1alpha1.FrameStateSucceeded},
+			},
+		},
+	}
+
+	Rerun(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"a": &success,
+		"b": &failed,
+		"c": nil,
+		"d": nil,
+		"e": nil,
+	})
+	if play.Status.Failed() {
+		t.Errorf("Play shouldn't be failed after a rerun")
+	}
+
+	job, err := generateActionJob(play, "main", "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "deploy-rerun-1-test"; job.Name != want {
+		t.Errorf("Want job name %s, got %s", want, job.Name)
+	}
+	if want := "1"; job.Annotations[ActionAnnotationRun] != want {
+		t.Errorf("Want run annotation %s, got %s", want, job.Annotations[ActionAnnotationRun])
+	}
+}
@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"time"
+
+	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// retryFrames moves failed frames which can be retried back to pending. Failed attempts are
+// recorded in the status of the frame, which is played again with a new Job once its backoff expires.
+func retryFrames(play *corev1alpha1.Play) {
+	if play.Spec.Cancel {
+		return
+	}
+	for _, frame := range play.AllFrames() {
+		status, ok := play.Status.Frames[frame.ID]
+		if !ok || !shouldRetry(frame, status) {
+			continue
+		}
+
+		retries := append(append([]corev1alpha1.FrameAttempt{}, status.Retries...), corev1alpha1.FrameAttempt{
+			JobRef:             status.JobRef,
+			StartTime:          status.StartTime,
+			CompletionTime:     status.CompletionTime,
+			ExitCodes:          status.ExitCodes,
+			TerminationMessage: status.TerminationMessage,
+		})
+		retryAfter := metav1.NewTime(time.Now().Add(backoffDelay(frame.Retry.Backoff, len(status.Retries))))
+		play.Status.SetFrameStatus(frame.ID, corev1alpha1.FrameStatus{
+			Name:           status.Name,
+			Scene:          status.Scene,
+			Screenplay:     status.Screenplay,
+			State:          corev1alpha1.FrameStatePending,
+			FailureAllowed: status.FailureAllowed,
+			Message:        fmt.Sprintf("Retry %d of %d", len(retries), frame.Retry.Limit),
+			Retries:        retries,
+			RetryAfter:     &retryAfter,
+		})
+	}
+}
+
+// shouldRetry checks if a frame failed in a way its retry policy allows to retry
+func shouldRetry(frame *corev1alpha1.Frame, status corev1alpha1.FrameStatus) bool {
+	if frame.Retry == nil || frame.Action == nil || status.State != corev1alpha1.FrameStateFailed {
+		return false
+	}
+	// Frames failed by a timeout would only run out of time again
+	if status.Reason == corev1alpha1.FrameReasonTimeout {
+		return false
+	}
+	if int32(len(status.Retries)) >= frame.Retry.Limit {
+		return false
+	}
+	return retryOn(frame.Retry.RetryOn, status)
+}
+
+// retryOn checks if the failure described by the status matches any of the retry conditions
+func retryOn(conditions *corev1alpha1.RetryOn, status corev1alpha1.FrameStatus) bool {
+	if conditions == nil {
+		return true
+	}
+	for _, exitCode := range status.ExitCodes {
+		for _, retried := range conditions.ExitCodes {
+			if exitCode == retried {
+				return true
+			}
+		}
+	}
+	if conditions.TerminationMessage != "" {
+		matched, err := regexp.MatchString(conditions.TerminationMessage, status.TerminationMessage)
+		if err != nil {
+			log.Errorf("Invalid termination message expression '%s': %s", conditions.TerminationMessage, err)
+			return false
+		}
+		return matched
+	}
+	return false
+}
+
+// backoffDelay returns the delay before a retry. The base delay doubles with every previous retry.
+func backoffDelay(backoff *corev1alpha1.Backoff, retries int) time.Duration {
+	if backoff == nil {
+		return 0
+	}
+	delay := backoff.Base.Duration
+	for i := 0; i < retries && delay < math.MaxInt64/2; i++ {
+		delay *= 2
+	}
+	if backoff.Max != nil && delay > backoff.Max.Duration {
+		return backoff.Max.Duration
+	}
+	return delay
+}
+
+// retryPending checks if a frame is waiting for its backoff to expire
+func retryPending(play *corev1alpha1.Play, frameID string) bool {
+	status, ok := play.Status.Frames[frameID]
+	return ok && status.State == corev1alpha1.FrameStatePending &&
+		status.RetryAfter != nil && time.Now().Before(status.RetryAfter.Time)
+}
+
+// NextRetry returns the earliest time when a frame of the Play waiting for a retry should be played
+func NextRetry(play *corev1alpha1.Play) *time.Time {
+	var next *time.Time
+	for frameID, status := range play.Status.Frames {
+		if !retryPending(play, frameID) {
+			continue
+		}
+		if next == nil || status.RetryAfter.Time.Before(*next) {
+			retryAfter := status.RetryAfter.Time
+			next = &retryAfter
+		}
+	}
+	return next
+}
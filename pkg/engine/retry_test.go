@@ -0,0 +1,241 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
+	"github.com/kuberik/engine/pkg/engine/scheduler"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNextRetry(t *testing.T) {
+	play := &corev1alpha1.Play{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{{
+					Name: "test",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "a",
+						Name:   "flaky",
+						Action: helloWorldAction(),
+						Retry:  &corev1alpha1.RetryPolicy{Limit: 2},
+					}},
+				}, {
+					Name: "release",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "b",
+						Name:   "release",
+						Action: helloWorldAction(),
+					}},
+				}},
+			}},
+		},
+	}
+	flow := NewFlow(&scheduler.DummyScheduler{Play: play, Result: corev1alpha1.FrameStateFailed})
+
+	for i := 0; i < 3; i++ {
+		flow.Next(play)
+		assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+			"a": &failed,
+			"b": nil,
+		})
+	}
+	if retries := len(play.Status.Frames["a"].Retries); retries != 2 {
+		t.Errorf("Want 2 retries, got %d", retries)
+	}
+	if job := newAction(play, "a", *play.Frame("a").Action); job.Name != "flaky-retry-2" {
+		t.Errorf("Want job name flaky-retry-2, got %s", job.Name)
+	}
+
+	// Limit of retries is exhausted
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"a": &failed,
+		"b": &skipped,
+	})
+}
+
+func TestNextRetrySucceeds(t *testing.T) {
+	play := &corev1alpha1.Play{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{{
+					Name: "test",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "a",
+						Name:   "flaky",
+						Action: helloWorldAction(),
+						Retry:  &corev1alpha1.RetryPolicy{Limit: 3},
+					}},
+				}, {
+					Name: "release",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "b",
+						Name:   "release",
+						Action: helloWorldAction(),
+					}},
+				}},
+			}},
+		},
+	}
+	dummy := &scheduler.DummyScheduler{Play: play, Result: corev1alpha1.FrameStateFailed}
+	flow := NewFlow(dummy)
+
+	flow.Next(play)
+	dummy.Result = corev1alpha1.FrameStateSucceeded
+	flow.Next(play)
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"a": &success,
+		"b": &success,
+	})
+	if retries := len(play.Status.Frames["a"].Retries); retries != 1 {
+		t.Errorf("Want 1 retry, got %d", retries)
+	}
+}
+
+func TestNextRetryBackoff(t *testing.T) {
+	play := &corev1alpha1.Play{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{{
+					Name: "test",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "a",
+						Name:   "flaky",
+						Action: helloWorldAction(),
+						Retry: &corev1alpha1.RetryPolicy{
+							Limit: 1,
+							Backoff: &corev1alpha1.Backoff{
+								Base: metav1.Duration{Duration: time.Minute},
+							},
+						},
+					}},
+				}, {
+					Name: "release",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "b",
+						Name:   "release",
+						Action: helloWorldAction(),
+					}},
+				}},
+			}},
+		},
+	}
+	flow := NewFlow(&scheduler.DummyScheduler{Play: play, Result: corev1alpha1.FrameStateFailed})
+
+	flow.Next(play)
+	flow.Next(play)
+	pending := corev1alpha1.FrameStatePending
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"a": nil,
+		"b": nil,
+	})
+	if state := play.Status.FrameState("a"); state != pending {
+		t.Errorf("Frame waiting for a retry want state %s, got %s", pending, state)
+	}
+	if next := NextRetry(play); next == nil || time.Until(*next) > time.Minute {
+		t.Errorf("Unexpected time of the next retry: %v", next)
+	}
+
+	status := play.Status.Frames["a"]
+	status.RetryAfter = &metav1.Time{Time: time.Now().Add(-time.Second)}
+	play.Status.SetFrameStatus("a", status)
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"a": &failed,
+		"b": nil,
+	})
+	if next := NextRetry(play); next != nil {
+		t.Errorf("Played frame shouldn't wait for a retry, got %v", next)
+	}
+}
+
+func TestNextRetryOn(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		retryOn *corev1alpha1.RetryOn
+		status  corev1alpha1.FrameStatus
+		retried bool
+	}{{
+		name:    "ExitCode",
+		retryOn: &corev1alpha1.RetryOn{ExitCodes: []int32{137}},
+		status:  corev1alpha1.FrameStatus{ExitCodes: map[string]int32{"main": 137}},
+		retried: true,
+	}, {
+		name:    "OtherExitCode",
+		retryOn: &corev1alpha1.RetryOn{ExitCodes: []int32{137}},
+		status:  corev1alpha1.FrameStatus{ExitCodes: map[string]int32{"main": 1}},
+		retried: false,
+	}, {
+		name:    "TerminationMessage",
+		retryOn: &corev1alpha1.RetryOn{TerminationMessage: "connection (reset|refused)"},
+		status:  corev1alpha1.FrameStatus{TerminationMessage: "dial tcp: connection refused"},
+		retried: true,
+	}, {
+		name:    "OtherTerminationMessage",
+		retryOn: &corev1alpha1.RetryOn{TerminationMessage: "connection (reset|refused)"},
+		status:  corev1alpha1.FrameStatus{TerminationMessage: "assertion failed"},
+		retried: false,
+	}, {
+		name:    "Timeout",
+		status:  corev1alpha1.FrameStatus{Reason: corev1alpha1.FrameReasonTimeout},
+		retried: false,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			play := &corev1alpha1.Play{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test",
+				},
+				Spec: corev1alpha1.PlaySpec{
+					Screenplays: []corev1alpha1.Screenplay{{
+						Name: "main",
+						Scenes: []corev1alpha1.Scene{{
+							Name: "test",
+							Frames: []corev1alpha1.Frame{{
+								ID:     "a",
+								Name:   "flaky",
+								Action: helloWorldAction(),
+								Retry:  &corev1alpha1.RetryPolicy{Limit: 1, RetryOn: tc.retryOn},
+							}},
+						}},
+					}},
+				},
+			}
+			tc.status.State = corev1alpha1.FrameStateFailed
+			play.Status.SetFrameStatus("a", tc.status)
+			retryFrames(play)
+			if retried := play.Status.FrameState("a") == corev1alpha1.FrameStatePending; retried != tc.retried {
+				t.Errorf("Frame retried want %v, got %v", tc.retried, retried)
+			}
+		})
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	backoff := &corev1alpha1.Backoff{
+		Base: metav1.Duration{Duration: 10 * time.Second},
+		Max:  &metav1.Duration{Duration: time.Minute},
+	}
+	for retries, want := range []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, time.Minute, time.Minute} {
+		if got := backoffDelay(backoff, retries); got != want {
+			t.Errorf("Delay of retry %d want %s, got %s", retries, want, got)
+		}
+	}
+	if got := backoffDelay(nil, 3); got != 0 {
+		t.Errorf("Retries without backoff shouldn't be delayed, got %s", got)
+	}
+}
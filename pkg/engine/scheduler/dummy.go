@@ -8,8 +8,9 @@ import (
 
 // DummyScheduler implements Scheduler interface but doesn't run any workload
 type DummyScheduler struct {
-	// Result is a value that dummy scheduler sets as a result status of any frame played
-	Result corev1alpha1.FrameStatus
+	// Result is a value that dummy scheduler sets as a result state of any frame played.
+	// Frames succeed if it's empty.
+	Result corev1alpha1.FrameState
 	Play   *corev1alpha1.Play
 }
 
@@ -27,7 +28,16 @@ func (s *DummyScheduler) Deprovision(resource []*resource.Resource) error {
 
 // Run implements Scheduler interface
 func (s *DummyScheduler) Run(job batchv1.Job) error {
+	result := s.Result
+	if result == "" {
+		result = corev1alpha1.FrameStateSucceeded
+	}
 	// TODO: replace hardcoded value
-	s.Play.Status.SetFrameStatus(job.Annotations["core.kuberik.io/frameID"], s.Result)
+	s.Play.Status.SetFrameState(job.Annotations["core.kuberik.io/frameID"], result)
+	return nil
+}
+
+// Cancel doesn't do anything for DummyScheduler
+func (s *DummyScheduler) Cancel(job batchv1.Job) error {
 	return nil
 }
@@ -8,6 +8,7 @@ import (
 
 	batchv1 "k8s.io/api/batch/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -52,7 +53,7 @@ func (ks *KubernetesScheduler) Provision(resources []*resource.Resource) error {
 func (ks *KubernetesScheduler) deleteObjects(objects ...controllerutil.Object) error {
 	for _, r := range objects {
 		err := ks.client.Delete(context.TODO(), r)
-		if err != nil && !errors.IsAlreadyExists(err) {
+		if err != nil && !errors.IsNotFound(err) {
 			return err
 		}
 	}
@@ -66,3 +67,12 @@ func (ks *KubernetesScheduler) Deprovision(resources []*resource.Resource) error
 func (ks *KubernetesScheduler) Run(job batchv1.Job) error {
 	return ks.createObjects(&job)
 }
+
+// Cancel deletes a Job together with its Pods
+func (ks *KubernetesScheduler) Cancel(job batchv1.Job) error {
+	err := ks.client.Delete(context.TODO(), &job, client.PropagationPolicy(metav1.DeletePropagationBackground))
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
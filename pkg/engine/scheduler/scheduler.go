@@ -8,6 +8,8 @@ import (
 // Scheduler implements a way for launching Actions
 type Scheduler interface {
 	Run(batchv1.Job) error
+	// Cancel stops a Job started by Run
+	Cancel(batchv1.Job) error
 	provisioner
 }
 
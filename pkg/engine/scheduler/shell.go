@@ -1,6 +1,7 @@
 package scheduler
 
 import (
+	"fmt"
 	"os/exec"
 
 	batchv1 "k8s.io/api/batch/v1"
@@ -12,14 +13,20 @@ type ShellScheduler struct{}
 
 var _ Scheduler = &ShellScheduler{}
 
-// Provision is not implemented for ShellScheduler
+// Provision is not supported by ShellScheduler and fails if there are any resources to provision
 func (s *ShellScheduler) Provision(resource []*resource.Resource) error {
-	panic("not implemented")
+	if len(resource) > 0 {
+		return fmt.Errorf("provisioning resources is not supported by ShellScheduler")
+	}
+	return nil
 }
 
-// Deprovision is not implemented for ShellScheduler
+// Deprovision is not supported by ShellScheduler and fails if there are any resources to deprovision
 func (s *ShellScheduler) Deprovision(resource []*resource.Resource) error {
-	panic("not implemented")
+	if len(resource) > 0 {
+		return fmt.Errorf("deprovisioning resources is not supported by ShellScheduler")
+	}
+	return nil
 }
 
 // Run implements Scheduler interface
@@ -40,3 +47,8 @@ func (s *ShellScheduler) Run(job batchv1.Job) error {
 
 	return nil
 }
+
+// Cancel is not supported by ShellScheduler and leaves started processes running
+func (s *ShellScheduler) Cancel(job batchv1.Job) error {
+	return nil
+}
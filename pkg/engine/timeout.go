@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
+)
+
+// timeout describes frames which need to finish before a deadline
+type timeout struct {
+	deadline   time.Time
+	screenplay string
+	scene      string
+	frames     []corev1alpha1.Frame
+	message    string
+}
+
+// timeouts lists timeouts of the Play, its scenes and frames which already started
+func timeouts(play *corev1alpha1.Play) (result []timeout) {
+	// Timeout of the Play stops the main screenplay together with the stories it started.
+	// Closing credits, including the stories they play, are played even after the Play times out.
+	if screenplay := play.Screenplay(mainScreenplayName); screenplay != nil && play.Spec.Timeout != nil && play.Status.StartTime != nil {
+		scenes := screenplay.Scenes
+		if screenplay.Credits != nil {
+			scenes = append([]corev1alpha1.Scene{{Frames: screenplay.Credits.Opening}}, scenes...)
+		}
+		for _, scene := range scenes {
+			result = append(result, timeout{
+				deadline:   play.Status.StartTime.Add(play.Spec.Timeout.Duration),
+				screenplay: screenplay.Name,
+				scene:      scene.Name,
+				frames:     scene.Frames,
+				message:    fmt.Sprintf("Play didn't finish in %s", play.Spec.Timeout.Duration),
+			})
+		}
+	}
+
+	for _, screenplay := range play.Spec.Screenplays {
+		for _, scene := range screenplay.Scenes {
+			if start := framesStartTime(&play.Status, scene.Frames); scene.Timeout != nil && start != nil {
+				result = append(result, timeout{
+					deadline:   start.Add(scene.Timeout.Duration),
+					screenplay: screenplay.Name,
+					scene:      scene.Name,
+					frames:     scene.Frames,
+					message:    fmt.Sprintf("Scene didn't finish in %s", scene.Timeout.Duration),
+				})
+			}
+		}
+
+		for _, frame := range screenplayFrames(&screenplay) {
+			status, ok := play.Status.Frames[frame.ID]
+			if frame.Timeout == nil || !ok || status.StartTime == nil {
+				continue
+			}
+			result = append(result, timeout{
+				deadline:   status.StartTime.Add(frame.Timeout.Duration),
+				screenplay: screenplay.Name,
+				scene:      status.Scene,
+				frames:     []corev1alpha1.Frame{frame},
+				message:    fmt.Sprintf("Frame didn't finish in %s", frame.Timeout.Duration),
+			})
+		}
+	}
+	return
+}
+
+// framesStartTime returns the time when the first of the frames started
+func framesStartTime(status *corev1alpha1.PlayStatus, frames []corev1alpha1.Frame) *time.Time {
+	var start *time.Time
+	for _, frame := range frames {
+		frameStatus := status.Frames[frame.ID]
+		frameStart := frameStatus.StartTime
+		// Retried frames started with their first attempt
+		if len(frameStatus.Retries) > 0 && frameStatus.Retries[0].StartTime != nil {
+			frameStart = frameStatus.Retries[0].StartTime
+		}
+		if frameStart != nil && (start == nil || frameStart.Time.Before(*start)) {
+			start = &frameStart.Time
+		}
+	}
+	return start
+}
+
+// enforceTimeouts fails the frames which are still running after their deadline expired
+func (f *Flow) enforceTimeouts(play *corev1alpha1.Play) error {
+	now := time.Now()
+	for _, t := range timeouts(play) {
+		if now.Before(t.deadline) || framesFinished(&play.Status, t.frames) {
+			continue
+		}
+		// Frames which didn't start fail as well, so the timeout fails the Play
+		finishPendingFrames(play, t.screenplay, t.scene, t.frames, corev1alpha1.FrameStateFailed, corev1alpha1.FrameReasonTimeout, t.message)
+		if err := f.cancelFrames(play, t.screenplay, t.scene, t.frames, corev1alpha1.FrameStateFailed, corev1alpha1.FrameReasonTimeout, t.message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NextTimeout returns the earliest deadline of frames of the Play which didn't finish yet
+func NextTimeout(play *corev1alpha1.Play) *time.Time {
+	var next *time.Time
+	for _, t := range timeouts(play) {
+		if framesFinished(&play.Status, t.frames) {
+			continue
+		}
+		if next == nil || t.deadline.Before(*next) {
+			deadline := t.deadline
+			next = &deadline
+		}
+	}
+	return next
+}
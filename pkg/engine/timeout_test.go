@@ -0,0 +1,228 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
+	"github.com/kuberik/engine/pkg/engine/scheduler"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNextFrameTimeout(t *testing.T) {
+	play := &corev1alpha1.Play{
+		Spec: corev1alpha1.PlaySpec{
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{{
+					Name: "test",
+					Frames: []corev1alpha1.Frame{{
+						ID:      "a",
+						Name:    "integration",
+						Action:  helloWorldAction(),
+						Timeout: &metav1.Duration{Duration: time.Minute},
+					}, {
+						ID:     "b",
+						Name:   "unit",
+						Action: helloWorldAction(),
+					}},
+				}, {
+					Name: "release",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "c",
+						Name:   "release",
+						Action: helloWorldAction(),
+					}},
+				}},
+				Credits: &corev1alpha1.Credits{
+					Closing: []corev1alpha1.Frame{{
+						ID:     "d",
+						Name:   "cleanup",
+						Action: helloWorldAction(),
+					}},
+				},
+			}},
+		},
+	}
+	running := corev1alpha1.FrameStateRunning
+
+	flow := NewFlow(&scheduler.DummyScheduler{Play: play, Result: running})
+	flow.Next(play)
+	if deadline := NextTimeout(play); deadline == nil || time.Until(*deadline) > time.Minute {
+		t.Errorf("Unexpected deadline of the frame: %v", deadline)
+	}
+
+	status := play.Status.Frames["a"]
+	status.StartTime = &metav1.Time{Time: time.Now().Add(-2 * time.Minute)}
+	play.Status.SetFrameStatus("a", status)
+	play.Status.SetFrameState("b", success)
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"a": &failed,
+		"b": &success,
+		"c": &skipped,
+		"d": &running,
+	})
+	if reason := play.Status.Frames["a"].Reason; reason != corev1alpha1.FrameReasonTimeout {
+		t.Errorf("Frame reason want %s, got %s", corev1alpha1.FrameReasonTimeout, reason)
+	}
+	if deadline := NextTimeout(play); deadline != nil {
+		t.Errorf("Timed out frames shouldn't have a deadline, got %v", deadline)
+	}
+}
+
+func TestNextPlayTimeout(t *testing.T) {
+	play := &corev1alpha1.Play{
+		Spec: corev1alpha1.PlaySpec{
+			Timeout: &metav1.Duration{Duration: time.Hour},
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{{
+					Name: "test",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "a",
+						Name:   "integration",
+						Action: helloWorldAction(),
+					}, {
+						ID:     "b",
+						Name:   "unit",
+						Action: helloWorldAction(),
+					}},
+				}, {
+					Name: "release",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "c",
+						Name:   "release",
+						Action: helloWorldAction(),
+					}},
+				}},
+				Credits: &corev1alpha1.Credits{
+					Closing: []corev1alpha1.Frame{{
+						ID:     "d",
+						Name:   "cleanup",
+						Action: helloWorldAction(),
+					}},
+				},
+			}},
+		},
+		Status: corev1alpha1.PlayStatus{
+			StartTime: &metav1.Time{Time: time.Now().Add(-2 * time.Hour)},
+		},
+	}
+	running := corev1alpha1.FrameStateRunning
+
+	flow := NewFlow(&scheduler.DummyScheduler{Play: play, Result: running})
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"a": &failed,
+		"b": &failed,
+		"c": &failed,
+		"d": &running,
+	})
+
+	play.Status.SetFrameState("d", success)
+	if err := flow.Next(play); !IsPlayEndedErorr(err) {
+		t.Errorf("Play should have ended")
+	}
+	if !play.Status.Failed() {
+		t.Errorf("Play should have failed")
+	}
+}
+
+func TestNextPlayTimeoutInClosingCredits(t *testing.T) {
+	release := "release"
+	cleanup := "cleanup"
+	play := &corev1alpha1.Play{
+		Spec: corev1alpha1.PlaySpec{
+			Timeout: &metav1.Duration{Duration: time.Hour},
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Scenes: []corev1alpha1.Scene{{
+					Name: "test",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "a",
+						Name:   "integration",
+						Action: helloWorldAction(),
+					}},
+				}, {
+					Name: "release",
+					Frames: []corev1alpha1.Frame{{
+						ID:    "b",
+						Name:  "release",
+						Story: &release,
+					}},
+				}},
+				Credits: &corev1alpha1.Credits{
+					Closing: []corev1alpha1.Frame{{
+						ID:    "c",
+						Name:  "cleanup",
+						Story: &cleanup,
+					}},
+				},
+			}, {
+				Name: release,
+				Scenes: []corev1alpha1.Scene{{
+					Name: "release",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "d",
+						Name:   "release",
+						Action: helloWorldAction(),
+					}},
+				}},
+			}, {
+				Name: cleanup,
+				Scenes: []corev1alpha1.Scene{{
+					Name: "cleanup",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "e",
+						Name:   "cleanup",
+						Action: helloWorldAction(),
+					}},
+				}},
+			}},
+		},
+	}
+	running := corev1alpha1.FrameStateRunning
+
+	flow := NewFlow(&scheduler.DummyScheduler{Play: play, Result: running})
+	flow.Next(play)
+	play.Status.SetFrameState("a", success)
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"a": &success,
+		"b": &running,
+		"c": nil,
+		"d": &running,
+		"e": nil,
+	})
+
+	play.Status.StartTime = &metav1.Time{Time: time.Now().Add(-2 * time.Hour)}
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"a": &success,
+		"b": &failed,
+		"c": &running,
+		"d": &failed,
+		"e": &running,
+	})
+
+	// Stories played by the closing credits don't time out with the Play
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"c": &running,
+		"e": &running,
+	})
+
+	play.Status.SetFrameState("e", success)
+	flow.Next(play)
+	assertFrameState(t, play, map[string]*corev1alpha1.FrameState{
+		"c": &success,
+		"e": &success,
+	})
+	if err := flow.Next(play); !IsPlayEndedErorr(err) {
+		t.Errorf("Play should have ended")
+	}
+	if !play.Status.Failed() {
+		t.Errorf("Play should have failed")
+	}
+}
@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"fmt"
+
+	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	varsVolumeName = "kuberik-vars"
+	varsMountPath  = "/kuberik/vars"
+	// varAnnotationPrefix prefixes annotations of Pods which hold values of vars mounted as files
+	varAnnotationPrefix = "vars.core.kuberik.io/"
+)
+
+// addVars injects vars of the Play and of a screenplay into all the containers of an action,
+// both as environment variables and as files under /kuberik/vars
+func addVars(play *corev1alpha1.Play, screenplay string, action *corev1alpha1.Action) {
+	s := play.Screenplay(screenplay)
+	if s == nil {
+		return
+	}
+	vars := mergeVars(play.Spec.Vars, s.Vars)
+	if len(vars) == 0 {
+		return
+	}
+	injectVars(action, vars, eventData(play))
+}
+
+// mergeVars returns vars of the Play overridden by vars of a screenplay with the same name
+func mergeVars(playVars, screenplayVars []corev1alpha1.Var) (vars []corev1alpha1.Var) {
+	overridden := make(map[string]bool)
+	for _, v := range screenplayVars {
+		overridden[v.Name] = true
+	}
+	for _, v := range playVars {
+		if !overridden[v.Name] {
+			vars = append(vars, v)
+		}
+	}
+	return append(vars, screenplayVars...)
+}
+
+func injectVars(action *corev1alpha1.Action, vars []corev1alpha1.Var, event map[string]string) {
+	podSpec := &action.Template.Spec
+	var env []corev1.EnvVar
+	var items []corev1.DownwardAPIVolumeFile
+	var sources []corev1.VolumeProjection
+	for _, v := range vars {
+		switch {
+		case v.ValueFrom != nil && v.ValueFrom.ConfigMapKeyRef != nil:
+			env = append(env, corev1.EnvVar{Name: v.Name, ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: v.ValueFrom.ConfigMapKeyRef}})
+			sources = append(sources, corev1.VolumeProjection{ConfigMap: &corev1.ConfigMapProjection{
+				LocalObjectReference: v.ValueFrom.ConfigMapKeyRef.LocalObjectReference,
+				Items:                []corev1.KeyToPath{{Key: v.ValueFrom.ConfigMapKeyRef.Key, Path: v.Name}},
+				Optional:             v.ValueFrom.ConfigMapKeyRef.Optional,
+			}})
+		case v.ValueFrom != nil && v.ValueFrom.SecretKeyRef != nil:
+			env = append(env, corev1.EnvVar{Name: v.Name, ValueFrom: &corev1.EnvVarSource{SecretKeyRef: v.ValueFrom.SecretKeyRef}})
+			sources = append(sources, corev1.VolumeProjection{Secret: &corev1.SecretProjection{
+				LocalObjectReference: v.ValueFrom.SecretKeyRef.LocalObjectReference,
+				Items:                []corev1.KeyToPath{{Key: v.ValueFrom.SecretKeyRef.Key, Path: v.Name}},
+				Optional:             v.ValueFrom.SecretKeyRef.Optional,
+			}})
+		default:
+			value := v.Value
+			if v.ValueFrom != nil {
+				value = event[v.ValueFrom.EventField]
+			}
+			env = append(env, corev1.EnvVar{Name: v.Name, Value: value})
+			// Literal values are mounted through annotations of the Pod, so no other resources are needed
+			if action.Template.Annotations == nil {
+				action.Template.Annotations = make(map[string]string)
+			}
+			action.Template.Annotations[varAnnotationPrefix+v.Name] = value
+			items = append(items, corev1.DownwardAPIVolumeFile{
+				Path: v.Name,
+				FieldRef: &corev1.ObjectFieldSelector{
+					FieldPath: fmt.Sprintf("metadata.annotations['%s%s']", varAnnotationPrefix, v.Name),
+				},
+			})
+		}
+	}
+	if len(items) > 0 {
+		sources = append([]corev1.VolumeProjection{{DownwardAPI: &corev1.DownwardAPIProjection{Items: items}}}, sources...)
+	}
+
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name: varsVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{Sources: sources},
+		},
+	})
+	mount := corev1.VolumeMount{
+		Name:      varsVolumeName,
+		MountPath: varsMountPath,
+		ReadOnly:  true,
+	}
+	// Environment variables defined by containers themselves take precedence
+	for ci := range podSpec.Containers {
+		podSpec.Containers[ci].Env = append(append([]corev1.EnvVar{}, env...), podSpec.Containers[ci].Env...)
+		podSpec.Containers[ci].VolumeMounts = append(podSpec.Containers[ci].VolumeMounts, mount)
+	}
+	for ci := range podSpec.InitContainers {
+		podSpec.InitContainers[ci].Env = append(append([]corev1.EnvVar{}, env...), podSpec.InitContainers[ci].Env...)
+		podSpec.InitContainers[ci].VolumeMounts = append(podSpec.InitContainers[ci].VolumeMounts, mount)
+	}
+}
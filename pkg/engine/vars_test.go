@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+
+	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestGenerateJobWithVars(t *testing.T) {
+	action := helloWorldAction()
+	action.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "OWN", Value: "container"}}
+	play := &corev1alpha1.Play{
+		Spec: corev1alpha1.PlaySpec{
+			Vars: []corev1alpha1.Var{{
+				Name:  "ENVIRONMENT",
+				Value: "staging",
+			}, {
+				Name:  "REGION",
+				Value: "eu",
+			}},
+			Screenplays: []corev1alpha1.Screenplay{{
+				Name: "main",
+				Vars: []corev1alpha1.Var{{
+					Name:  "ENVIRONMENT",
+					Value: "production",
+				}, {
+					Name: "TOKEN",
+					ValueFrom: &corev1alpha1.VarSource{
+						SecretKeyRef: &corev1.SecretKeySelector{
+							LocalObjectReference: corev1.LocalObjectReference{Name: "credentials"},
+							Key:                  "token",
+						},
+					},
+				}, {
+					Name:      "BRANCH",
+					ValueFrom: &corev1alpha1.VarSource{EventField: "branch"},
+				}},
+				Provision: corev1alpha1.Provision{
+					Resources: []runtime.RawExtension{{
+						Raw: []byte(`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "event-data"}, "data": {"branch": "main"}}`),
+					}},
+				},
+				Scenes: []corev1alpha1.Scene{{
+					Name: "deploy",
+					Frames: []corev1alpha1.Frame{{
+						ID:     "a",
+						Name:   "deploy",
+						Action: action,
+					}},
+				}},
+			}},
+		},
+	}
+	job, err := generateActionJob(play, "main", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	podTemplate := job.Spec.Template
+	container := podTemplate.Spec.Containers[0]
+	var names []string
+	for _, e := range container.Env {
+		names = append(names, e.Name)
+	}
+	if want := []string{"REGION", "ENVIRONMENT", "TOKEN", "BRANCH", "OWN"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Environment variables want %v, got %v", want, names)
+	}
+	if container.Env[1].Value != "production" || container.Env[3].Value != "main" {
+		t.Errorf("Unexpected values of vars: %v", container.Env)
+	}
+	if ref := container.Env[2].ValueFrom; ref == nil || ref.SecretKeyRef == nil || ref.SecretKeyRef.Name != "credentials" {
+		t.Errorf("Secret var isn't referenced, got %v", ref)
+	}
+
+	if want := "production"; podTemplate.Annotations[varAnnotationPrefix+"ENVIRONMENT"] != want {
+		t.Errorf("Annotation of the var want %s, got %s", want, podTemplate.Annotations[varAnnotationPrefix+"ENVIRONMENT"])
+	}
+	// Event data is provisioned as a ConfigMap, which has its own volume
+	volumes := podTemplate.Spec.Volumes
+	if len(volumes) != 2 || volumes[1].Name != varsVolumeName || volumes[1].Projected == nil {
+		t.Fatalf("Expected a projected volume with vars, got %v", volumes)
+	}
+	sources := volumes[1].Projected.Sources
+	if len(sources) != 2 || len(sources[0].DownwardAPI.Items) != 3 || sources[1].Secret.Items[0].Path != "TOKEN" {
+		t.Errorf("Unexpected sources of the vars volume: %v", sources)
+	}
+	if len(container.VolumeMounts) != 2 || container.VolumeMounts[1].MountPath != varsMountPath {
+		t.Errorf("Vars aren't mounted to %s, got %v", varsMountPath, container.VolumeMounts)
+	}
+}
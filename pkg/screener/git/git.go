@@ -0,0 +1,206 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
+	"github.com/kuberik/engine/pkg/screener"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// Type is the type of the git polling Screener
+	Type = "git"
+
+	// EventDataRepository is the key of Event data holding the polled repository
+	EventDataRepository = "repo"
+	// EventDataRef is the key of Event data holding the updated reference
+	EventDataRef = "ref"
+	// EventDataSHA is the key of Event data holding the new commit SHA of the reference
+	EventDataSHA = "sha"
+
+	branchRefPrefix = "refs/heads/"
+	defaultInterval = time.Minute
+)
+
+var (
+	// allowedSchemes are the schemes of repository URLs which can be polled
+	allowedSchemes = map[string]bool{"https": true, "http": true, "ssh": true, "git": true, "file": true}
+	// scpLikeRepository matches repositories in the scp-like syntax, e.g. git@github.com:kuberik/engine.git
+	scpLikeRepository = regexp.MustCompile(`^(\w[\w.~-]*@)?\w[\w.-]*:[^:]`)
+)
+
+// Config describes configuration of a git polling Screener
+type Config struct {
+	// Repository is the URL of the polled repository
+	Repository string `json:"repository"`
+
+	// Branches is a list of patterns matching names of the polled branches,
+	// in the format accepted by path.Match. All branches are polled if empty.
+	Branches []string `json:"branches,omitempty"`
+
+	// Interval between two polls of the repository. Defaults to one minute.
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// Screener creates Events for new commits of a git repository by polling it with git ls-remote
+type Screener struct{}
+
+var _ screener.Screener = &Screener{}
+
+// Screen implements screener.Screener interface
+func (s *Screener) Screen(ctx context.Context, screener *corev1alpha1.Screener, emitter screener.EventEmitter) error {
+	config := Config{}
+	if err := json.Unmarshal(screener.Spec.Config.Raw, &config); err != nil {
+		return fmt.Errorf("Invalid git config: %s", err)
+	}
+	if err := validateRepository(config.Repository); err != nil {
+		return fmt.Errorf("Invalid git config: %s", err)
+	}
+	for _, pattern := range config.Branches {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("Invalid branch pattern '%s': %s", pattern, err)
+		}
+	}
+	interval := defaultInterval
+	if config.Interval != nil && config.Interval.Duration > 0 {
+		interval = config.Interval.Duration
+	}
+
+	// Last seen SHAs by reference. Commits seen on the first poll
+	// are only recorded, so that existing branches don't trigger the Movie.
+	seen := screener.Status.State
+	for {
+		refs, err := lsRemote(ctx, config.Repository)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		updated := make(map[string]string)
+		for ref, sha := range refs {
+			if !matchBranch(config.Branches, ref) {
+				continue
+			}
+			updated[ref] = sha
+			if seen == nil || seen[ref] == sha {
+				continue
+			}
+
+			log.Infof("New commit %s on %s of %s", sha, ref, config.Repository)
+			err := emitter.Emit(ctx, eventName(screener.Name, ref, sha), map[string]string{
+				EventDataRepository: config.Repository,
+				EventDataRef:        ref,
+				EventDataSHA:        sha,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if !equalRefs(seen, updated) {
+			if err := emitter.SaveState(ctx, updated); err != nil {
+				return err
+			}
+		}
+		seen = updated
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// validateRepository checks that the repository is a URL with one of the allowed schemes
+// or in the scp-like syntax, so that it can't be mistaken for an option or a remote helper
+func validateRepository(repository string) error {
+	if repository == "" {
+		return fmt.Errorf("repository is required")
+	}
+	if strings.Contains(repository, "://") {
+		u, err := url.Parse(repository)
+		if err != nil {
+			return fmt.Errorf("invalid repository URL: %s", err)
+		}
+		if !allowedSchemes[u.Scheme] {
+			return fmt.Errorf("unsupported scheme '%s' of repository URL", u.Scheme)
+		}
+		return nil
+	}
+	if !scpLikeRepository.MatchString(repository) {
+		return fmt.Errorf("repository '%s' is neither a URL nor in the scp-like syntax", repository)
+	}
+	return nil
+}
+
+// lsRemote lists SHAs of the branches in a remote repository
+func lsRemote(ctx context.Context, repository string) (map[string]string, error) {
+	cmd := exec.CommandContext(ctx, "git", "ls-remote", "--heads", "--", repository)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git ls-remote failed: %s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	refs := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		refs[fields[1]] = fields[0]
+	}
+	return refs, scanner.Err()
+}
+
+func matchBranch(patterns []string, ref string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	branch := strings.TrimPrefix(ref, branchRefPrefix)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, branch); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func equalRefs(a, b map[string]string) bool {
+	if a == nil || len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// eventName generates a name of an Event which is unique for a commit on a reference
+func eventName(screenerName, ref, sha string) string {
+	hash := sha256.Sum256([]byte(ref + "@" + sha))
+	return fmt.Sprintf("%s-%s", screenerName, hex.EncodeToString(hash[:])[:10])
+}
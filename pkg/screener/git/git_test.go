@@ -0,0 +1,203 @@
+package git
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+type fakeEmitter struct {
+	events chan map[string]string
+	states chan map[string]string
+}
+
+func (e *fakeEmitter) Emit(ctx context.Context, name string, data map[string]string) error {
+	e.events <- data
+	return nil
+}
+
+func (e *fakeEmitter) SaveState(ctx context.Context, state map[string]string) error {
+	e.states <- state
+	return nil
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s failed: %s: %s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func commit(t *testing.T, dir, branch string) string {
+	runGit(t, dir, "commit", "--allow-empty", "-m", "commit")
+	runGit(t, dir, "push", "origin", "HEAD:refs/heads/"+branch)
+	return runGit(t, dir, "rev-parse", "HEAD")
+}
+
+func TestGitScreener(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	tmp, err := ioutil.TempDir("", "git-screener")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	remote := filepath.Join(tmp, "remote.git")
+	work := filepath.Join(tmp, "work")
+	runGit(t, tmp, "init", "--bare", remote)
+	runGit(t, tmp, "init", work)
+	runGit(t, work, "remote", "add", "origin", remote)
+	initial := commit(t, work, "master")
+	commit(t, work, "feature")
+
+	instance := &corev1alpha1.Screener{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "repo",
+			Namespace: "default",
+		},
+		Spec: corev1alpha1.ScreenerSpec{
+			Type: Type,
+			Config: runtime.RawExtension{Raw: []byte(`{
+				"repository": "file://` + remote + `",
+				"branches": ["master", "release-*"],
+				"interval": "10ms"
+			}`)},
+		},
+	}
+	emitter := &fakeEmitter{
+		events: make(chan map[string]string, 10),
+		states: make(chan map[string]string, 10),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 1)
+	go func() {
+		errs <- (&Screener{}).Screen(ctx, instance, emitter)
+	}()
+
+	// First poll only records the current state
+	select {
+	case state := <-emitter.states:
+		if len(state) != 1 || state["refs/heads/master"] != initial {
+			t.Fatalf("Unexpected initial state: %v", state)
+		}
+	case err := <-errs:
+		t.Fatalf("Screener stopped: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Initial state not saved")
+	}
+
+	commit(t, work, "feature")
+	sha := commit(t, work, "release-1")
+	select {
+	case data := <-emitter.events:
+		if data[EventDataRepository] != "file://"+remote || data[EventDataRef] != "refs/heads/release-1" || data[EventDataSHA] != sha {
+			t.Errorf("Unexpected event data: %v", data)
+		}
+	case err := <-errs:
+		t.Fatalf("Screener stopped: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Event not emitted for a new commit")
+	}
+
+	state := <-emitter.states
+	if state["refs/heads/release-1"] != sha || state["refs/heads/master"] != initial {
+		t.Errorf("Unexpected saved state: %v", state)
+	}
+	select {
+	case data := <-emitter.events:
+		t.Errorf("Unexpected event: %v", data)
+	default:
+	}
+
+	cancel()
+	if err := <-errs; err != nil {
+		t.Errorf("Screener returned an error: %v", err)
+	}
+}
+
+func TestGitScreenerResumesFromState(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	tmp, err := ioutil.TempDir("", "git-screener")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	remote := filepath.Join(tmp, "remote.git")
+	work := filepath.Join(tmp, "work")
+	runGit(t, tmp, "init", "--bare", remote)
+	runGit(t, tmp, "init", work)
+	runGit(t, work, "remote", "add", "origin", remote)
+	old := commit(t, work, "master")
+	sha := commit(t, work, "master")
+
+	instance := &corev1alpha1.Screener{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "repo",
+			Namespace: "default",
+		},
+		Spec: corev1alpha1.ScreenerSpec{
+			Type:   Type,
+			Config: runtime.RawExtension{Raw: []byte(`{"repository": "file://` + remote + `"}`)},
+		},
+		Status: corev1alpha1.ScreenerStatus{
+			State: map[string]string{"refs/heads/master": old},
+		},
+	}
+	emitter := &fakeEmitter{
+		events: make(chan map[string]string, 10),
+		states: make(chan map[string]string, 10),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go (&Screener{}).Screen(ctx, instance, emitter)
+
+	select {
+	case data := <-emitter.events:
+		if data[EventDataSHA] != sha {
+			t.Errorf("Expected event for %s, got %v", sha, data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Event not emitted for a commit pushed while the screener was stopped")
+	}
+}
+
+func TestValidateRepository(t *testing.T) {
+	for repository, valid := range map[string]bool{
+		"https://github.com/kuberik/engine.git": true,
+		"ssh://git@github.com/kuberik/engine":   true,
+		"git@github.com:kuberik/engine.git":     true,
+		"file:///srv/git/engine.git":            true,
+		"":                                      false,
+		"/srv/git/engine.git":                   false,
+		"--upload-pack=touch /tmp/pwned":        false,
+		"ext::sh -c touch% /tmp/pwned":          false,
+		"fd::17":                                false,
+		"ftp://example.com/engine.git":          false,
+	} {
+		if err := validateRepository(repository); (err == nil) != valid {
+			t.Errorf("Validation of repository '%s' want valid=%t, got error %v", repository, valid, err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package screener
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
+)
+
+// BuiltinClass is the class of Screeners implemented by the engine itself
+const BuiltinClass = "core.kuberik.io"
+
+// Screener watches for external occurrences which should trigger a Movie.
+// Every Screener object of a registered class and type gets its own running Screen.
+type Screener interface {
+	// Screen watches for occurrences described by the Screener object and
+	// emits an Event for each one of them. It blocks until the context is
+	// cancelled or screening fails.
+	Screen(ctx context.Context, screener *corev1alpha1.Screener, emitter EventEmitter) error
+}
+
+// EventEmitter creates Events for the Movie a Screener is watching for
+type EventEmitter interface {
+	// Emit creates an Event with provided data. Events with the same name are
+	// created only once, so the name can be used to deduplicate occurrences.
+	// Name is generated if it's empty.
+	Emit(ctx context.Context, name string, data map[string]string) error
+
+	// SaveState persists state of the Screener in its status. Saved state
+	// is available in the status of the Screener object when it's restarted.
+	SaveState(ctx context.Context, state map[string]string) error
+}
+
+type registryKey struct {
+	class      string
+	screenType string
+}
+
+// Registry holds implementations of Screeners registered under a class and type
+type Registry struct {
+	lock      sync.RWMutex
+	screeners map[registryKey]Screener
+	classes   map[string]bool
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{
+		screeners: make(map[registryKey]Screener),
+		classes:   make(map[string]bool),
+	}
+}
+
+// Register registers a Screener implementation under a class and type
+func (r *Registry) Register(class, screenType string, s Screener) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	key := registryKey{class, screenType}
+	if _, ok := r.screeners[key]; ok {
+		return fmt.Errorf("Screener of class '%s' and type '%s' already registered", class, screenType)
+	}
+	r.screeners[key] = s
+	r.classes[class] = true
+	return nil
+}
+
+// Screener gets a Screener registered under a class and type
+func (r *Registry) Screener(class, screenType string) (Screener, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	s, ok := r.screeners[registryKey{class, screenType}]
+	return s, ok
+}
+
+// HasClass checks if any Screener is registered under a class.
+// Screeners of other classes are handled by other controllers.
+func (r *Registry) HasClass(class string) bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.classes[class]
+}
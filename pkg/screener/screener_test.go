@@ -0,0 +1,38 @@
+package screener
+
+import (
+	"context"
+	"testing"
+
+	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
+)
+
+type nopScreener struct{}
+
+func (nopScreener) Screen(ctx context.Context, screener *corev1alpha1.Screener, emitter EventEmitter) error {
+	<-ctx.Done()
+	return nil
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	if err := r.Register("core.kuberik.io", "nop", nopScreener{}); err != nil {
+		t.Fatalf("Failed to register a screener: %s", err)
+	}
+	if err := r.Register("core.kuberik.io", "nop", nopScreener{}); err == nil {
+		t.Errorf("Screener registered twice under the same class and type")
+	}
+
+	if _, ok := r.Screener("core.kuberik.io", "nop"); !ok {
+		t.Errorf("Registered screener not found")
+	}
+	if _, ok := r.Screener("core.kuberik.io", "other"); ok {
+		t.Errorf("Found a screener which is not registered")
+	}
+	if !r.HasClass("core.kuberik.io") {
+		t.Errorf("Class of a registered screener not found")
+	}
+	if r.HasClass("example.com") {
+		t.Errorf("Found a class which is not registered")
+	}
+}
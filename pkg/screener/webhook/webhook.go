@@ -0,0 +1,227 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
+	"github.com/kuberik/engine/pkg/screener"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/jsonpath"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// Type is the type of the webhook Screener
+	Type = "webhook"
+
+	// PathPrefix is the prefix of paths on which the Screeners are served.
+	// Every Screener is served on a path /screeners/<namespace>/<name>.
+	PathPrefix = "/screeners/"
+
+	defaultSignatureHeader = "X-Hub-Signature-256"
+	signaturePrefix        = "sha256="
+
+	maxPayloadSize = 10 << 20
+)
+
+// Config describes configuration of a webhook Screener
+type Config struct {
+	// SecretRef selects a key of a Secret with a key used to validate HMAC SHA-256 signatures of the payloads
+	SecretRef *corev1.SecretKeySelector `json:"secretRef"`
+
+	// SignatureHeader is the name of the header containing the signature of the payload.
+	// Defaults to X-Hub-Signature-256.
+	SignatureHeader string `json:"signatureHeader,omitempty"`
+
+	// Data maps keys of Event data to JSONPath expressions evaluated against the JSON payload
+	Data map[string]string `json:"data,omitempty"`
+}
+
+// Screener creates Events from HTTP POST requests
+type Screener struct {
+	reader client.Reader
+	addr   string
+
+	lock     sync.RWMutex
+	handlers map[string]http.Handler
+}
+
+var _ screener.Screener = &Screener{}
+
+// NewScreener creates a webhook Screener serving on the provided address
+// which reads signing keys of the payloads with the provided reader
+func NewScreener(reader client.Reader, addr string) *Screener {
+	return &Screener{
+		reader:   reader,
+		addr:     addr,
+		handlers: make(map[string]http.Handler),
+	}
+}
+
+// Path returns the path on which a Screener is served
+func Path(namespace, name string) string {
+	return fmt.Sprintf("%s%s/%s", PathPrefix, namespace, name)
+}
+
+// Screen implements screener.Screener interface
+func (s *Screener) Screen(ctx context.Context, screener *corev1alpha1.Screener, emitter screener.EventEmitter) error {
+	config := Config{}
+	if err := json.Unmarshal(screener.Spec.Config.Raw, &config); err != nil {
+		return fmt.Errorf("Invalid webhook config: %s", err)
+	}
+	if config.SecretRef == nil {
+		return fmt.Errorf("Invalid webhook config: secretRef is required")
+	}
+	if config.SignatureHeader == "" {
+		config.SignatureHeader = defaultSignatureHeader
+	}
+
+	data := make(map[string]*jsonpath.JSONPath)
+	for k, v := range config.Data {
+		jp := jsonpath.New(k).AllowMissingKeys(true)
+		if err := jp.Parse(v); err != nil {
+			return fmt.Errorf("Invalid JSONPath for '%s' data: %s", k, err)
+		}
+		data[k] = jp
+	}
+
+	path := Path(screener.Namespace, screener.Name)
+	s.setHandler(path, &handler{
+		reader:  s.reader,
+		secret:  types.NamespacedName{Name: config.SecretRef.Name, Namespace: screener.Namespace},
+		key:     config.SecretRef.Key,
+		header:  config.SignatureHeader,
+		data:    data,
+		emitter: emitter,
+	})
+	defer s.setHandler(path, nil)
+
+	<-ctx.Done()
+	return nil
+}
+
+func (s *Screener) setHandler(path string, h http.Handler) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if h == nil {
+		delete(s.handlers, path)
+	} else {
+		s.handlers[path] = h
+	}
+}
+
+// ServeHTTP dispatches requests to the handlers of running Screeners
+func (s *Screener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.RLock()
+	h, ok := s.handlers[r.URL.Path]
+	s.lock.RUnlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	h.ServeHTTP(w, r)
+}
+
+// Start serves the webhooks until the stop channel is closed.
+// Screeners are served only by the leader, since their handlers are registered by the
+// Screener controller, so webhooks can be delivered only to a single replica of the manager.
+func (s *Screener) Start(stop <-chan struct{}) error {
+	mux := http.NewServeMux()
+	mux.Handle(PathPrefix, s)
+	server := &http.Server{Addr: s.addr, Handler: mux}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case <-stop:
+		return server.Shutdown(context.Background())
+	case err := <-errs:
+		return err
+	}
+}
+
+// handler serves requests of a single Screener
+type handler struct {
+	reader  client.Reader
+	secret  types.NamespacedName
+	key     string
+	header  string
+	data    map[string]*jsonpath.JSONPath
+	emitter screener.EventEmitter
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxPayloadSize))
+	if err != nil {
+		http.Error(w, "failed reading the payload", http.StatusBadRequest)
+		return
+	}
+
+	secret := &corev1.Secret{}
+	if err := h.reader.Get(r.Context(), h.secret, secret); err != nil {
+		log.Errorf("Failed to get webhook secret %s: %s", h.secret, err)
+		http.Error(w, "failed validating the signature", http.StatusInternalServerError)
+		return
+	}
+	if !validSignature(secret.Data[h.key], body, r.Header.Get(h.header)) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var payload interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, "payload is not a valid JSON", http.StatusBadRequest)
+		return
+	}
+
+	data := make(map[string]string)
+	for k, jp := range h.data {
+		buf := &bytes.Buffer{}
+		if err := jp.Execute(buf, payload); err != nil {
+			http.Error(w, fmt.Sprintf("failed evaluating '%s' data: %s", k, err), http.StatusBadRequest)
+			return
+		}
+		data[k] = buf.String()
+	}
+
+	if err := h.emitter.Emit(r.Context(), "", data); err != nil {
+		log.Errorf("Failed to create an event from webhook: %s", err)
+		http.Error(w, "failed creating an event", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func validSignature(key, payload []byte, signature string) bool {
+	if len(key) == 0 || !strings.HasPrefix(signature, signaturePrefix) {
+		return false
+	}
+	decoded, err := hex.DecodeString(strings.TrimPrefix(signature, signaturePrefix))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	return hmac.Equal(mac.Sum(nil), decoded)
+}
@@ -0,0 +1,117 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	corev1alpha1 "github.com/kuberik/engine/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type fakeEmitter struct {
+	events []map[string]string
+}
+
+func (e *fakeEmitter) Emit(ctx context.Context, name string, data map[string]string) error {
+	e.events = append(e.events, data)
+	return nil
+}
+
+func (e *fakeEmitter) SaveState(ctx context.Context, state map[string]string) error {
+	return nil
+}
+
+func sign(key, payload []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhook(t *testing.T) {
+	key := []byte("top-secret")
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "webhook",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{"key": key},
+	}
+	s := NewScreener(fake.NewFakeClientWithScheme(scheme.Scheme, secret), "")
+
+	instance := &corev1alpha1.Screener{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "push",
+			Namespace: "default",
+		},
+		Spec: corev1alpha1.ScreenerSpec{
+			Type: Type,
+			Config: runtime.RawExtension{Raw: []byte(`{
+				"secretRef": {"name": "webhook", "key": "key"},
+				"data": {"ref": "{.ref}", "sha": "{.head_commit.id}", "missing": "{.missing}"}
+			}`)},
+		},
+	}
+	emitter := &fakeEmitter{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Screen(ctx, instance, emitter)
+
+	payload := []byte(`{"ref": "refs/heads/main", "head_commit": {"id": "abc123"}}`)
+	post := func(signature string) int {
+		req := httptest.NewRequest(http.MethodPost, Path(instance.Namespace, instance.Name), bytes.NewReader(payload))
+		req.Header.Set(defaultSignatureHeader, signature)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for post(sign(key, payload)) == http.StatusNotFound {
+		if time.Now().After(deadline) {
+			t.Fatalf("Webhook was not served")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(emitter.events) != 1 {
+		t.Fatalf("Want 1 event to be emitted, got %d", len(emitter.events))
+	}
+	for k, want := range map[string]string{"ref": "refs/heads/main", "sha": "abc123", "missing": ""} {
+		if got := emitter.events[0][k]; got != want {
+			t.Errorf("Want '%s' for '%s' data, got '%s'", want, k, got)
+		}
+	}
+
+	if code := post(sign([]byte("wrong"), payload)); code != http.StatusUnauthorized {
+		t.Errorf("Want status %d for invalid signature, got %d", http.StatusUnauthorized, code)
+	}
+	if code := post(""); code != http.StatusUnauthorized {
+		t.Errorf("Want status %d for missing signature, got %d", http.StatusUnauthorized, code)
+	}
+	if len(emitter.events) != 1 {
+		t.Errorf("Events emitted for requests with invalid signatures")
+	}
+}
+
+func TestWebhookWithoutSecret(t *testing.T) {
+	s := NewScreener(fake.NewFakeClientWithScheme(scheme.Scheme), "")
+	instance := &corev1alpha1.Screener{
+		Spec: corev1alpha1.ScreenerSpec{
+			Type:   Type,
+			Config: runtime.RawExtension{Raw: []byte(`{}`)},
+		},
+	}
+	if err := s.Screen(context.Background(), instance, &fakeEmitter{}); err == nil {
+		t.Errorf("Webhook without a secret should not be served")
+	}
+}
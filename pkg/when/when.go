@@ -0,0 +1,214 @@
+// Package when evaluates expressions which decide if a frame should be played.
+//
+// Expressions use a subset of Go expression syntax. String literals, `true`, `false`,
+// variables, `==`, `!=`, `&&`, `||`, `!` and parentheses are supported.
+// Variables are accessed with selectors (`event.branch`) or with indexes
+// for keys which aren't valid identifiers (`annotations["example.com/key"]`).
+package when
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+)
+
+// Variables are values which can be referenced in expressions.
+// Values are either strings, booleans, string maps or nested Variables.
+type Variables map[string]interface{}
+
+// Expression is a parsed when expression
+type Expression struct {
+	source string
+	expr   ast.Expr
+}
+
+// Parse parses an expression and checks that only supported syntax is used
+func Parse(source string) (*Expression, error) {
+	expr, err := parser.ParseExpr(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse expression '%s': %s", source, err)
+	}
+	if err := validate(expr); err != nil {
+		return nil, fmt.Errorf("invalid expression '%s': %s", source, err)
+	}
+	return &Expression{source: source, expr: expr}, nil
+}
+
+// Validator validates expressions by parsing them
+type Validator struct{}
+
+// Validate checks that an expression can be parsed
+func (Validator) Validate(source string) error {
+	_, err := Parse(source)
+	return err
+}
+
+// Evaluate evaluates an expression with given variables. Result of the expression needs to be a boolean.
+func (e *Expression) Evaluate(vars Variables) (bool, error) {
+	value, err := evaluate(e.expr, vars)
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate expression '%s': %s", e.source, err)
+	}
+	result, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression '%s' evaluated to %q instead of a boolean", e.source, value)
+	}
+	return result, nil
+}
+
+// String returns the source of the expression
+func (e *Expression) String() string {
+	return e.source
+}
+
+func validate(expr ast.Expr) error {
+	switch n := expr.(type) {
+	case *ast.ParenExpr:
+		return validate(n.X)
+	case *ast.UnaryExpr:
+		if n.Op != token.NOT {
+			return fmt.Errorf("unsupported operator %s", n.Op)
+		}
+		return validate(n.X)
+	case *ast.BinaryExpr:
+		switch n.Op {
+		case token.EQL, token.NEQ, token.LAND, token.LOR:
+		default:
+			return fmt.Errorf("unsupported operator %s", n.Op)
+		}
+		if err := validate(n.X); err != nil {
+			return err
+		}
+		return validate(n.Y)
+	case *ast.BasicLit:
+		if n.Kind != token.STRING {
+			return fmt.Errorf("unsupported literal %s", n.Value)
+		}
+		return nil
+	case *ast.Ident:
+		return nil
+	case *ast.SelectorExpr:
+		return validate(n.X)
+	case *ast.IndexExpr:
+		if lit, ok := n.Index.(*ast.BasicLit); !ok || lit.Kind != token.STRING {
+			return fmt.Errorf("only string literals can be used as indexes")
+		}
+		return validate(n.X)
+	}
+	return fmt.Errorf("unsupported syntax")
+}
+
+func evaluate(expr ast.Expr, vars Variables) (interface{}, error) {
+	switch n := expr.(type) {
+	case *ast.ParenExpr:
+		return evaluate(n.X, vars)
+	case *ast.UnaryExpr:
+		x, err := evaluateBool(n.X, vars)
+		if err != nil {
+			return nil, err
+		}
+		return !x, nil
+	case *ast.BinaryExpr:
+		return evaluateBinary(n, vars)
+	case *ast.BasicLit:
+		return strconv.Unquote(n.Value)
+	case *ast.Ident:
+		switch n.Name {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		return lookup(vars, n.Name)
+	case *ast.SelectorExpr:
+		x, err := evaluate(n.X, vars)
+		if err != nil {
+			return nil, err
+		}
+		return lookup(x, n.Sel.Name)
+	case *ast.IndexExpr:
+		x, err := evaluate(n.X, vars)
+		if err != nil {
+			return nil, err
+		}
+		key, err := strconv.Unquote(n.Index.(*ast.BasicLit).Value)
+		if err != nil {
+			return nil, err
+		}
+		return lookup(x, key)
+	}
+	return nil, fmt.Errorf("unsupported syntax")
+}
+
+func evaluateBinary(n *ast.BinaryExpr, vars Variables) (interface{}, error) {
+	switch n.Op {
+	case token.LAND, token.LOR:
+		x, err := evaluateBool(n.X, vars)
+		if err != nil {
+			return nil, err
+		}
+		// Short-circuit like Go does
+		if (n.Op == token.LAND && !x) || (n.Op == token.LOR && x) {
+			return x, nil
+		}
+		return evaluateBool(n.Y, vars)
+	}
+
+	x, err := evaluate(n.X, vars)
+	if err != nil {
+		return nil, err
+	}
+	y, err := evaluate(n.Y, vars)
+	if err != nil {
+		return nil, err
+	}
+	if !comparableValues(x, y) {
+		return nil, fmt.Errorf("can't compare %q and %q", x, y)
+	}
+	if n.Op == token.EQL {
+		return x == y, nil
+	}
+	return x != y, nil
+}
+
+// comparableValues checks that both values are either strings or booleans
+func comparableValues(x, y interface{}) bool {
+	switch x.(type) {
+	case string:
+		_, ok := y.(string)
+		return ok
+	case bool:
+		_, ok := y.(bool)
+		return ok
+	}
+	return false
+}
+
+func evaluateBool(expr ast.Expr, vars Variables) (bool, error) {
+	value, err := evaluate(expr, vars)
+	if err != nil {
+		return false, err
+	}
+	result, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("%q is not a boolean", value)
+	}
+	return result, nil
+}
+
+// lookup finds a key in a value. Missing keys of string maps evaluate to an empty string,
+// while missing Variables are reported as errors.
+func lookup(value interface{}, key string) (interface{}, error) {
+	switch v := value.(type) {
+	case Variables:
+		if result, ok := v[key]; ok {
+			return result, nil
+		}
+		return nil, fmt.Errorf("unknown variable %s", key)
+	case map[string]string:
+		return v[key], nil
+	}
+	return nil, fmt.Errorf("can't access %s of %q", key, value)
+}
@@ -0,0 +1,93 @@
+package when
+
+import (
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	vars := Variables{
+		"event": map[string]string{
+			"branch": "main",
+		},
+		"annotations": map[string]string{
+			"example.com/deploy": "true",
+		},
+		"frames": Variables{
+			"test": map[string]string{
+				"status": "failed",
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		expression string
+		want       bool
+	}{
+		{`event.branch == "main"`, true},
+		{`event.branch != "main"`, false},
+		{`event.missing == ""`, true},
+		{`frames.test.status == "failed"`, true},
+		{`frames["test"].status == "succeeded"`, false},
+		{`annotations["example.com/deploy"] == "true"`, true},
+		{`event.branch == "main" && !(frames.test.status == "failed")`, false},
+		{`event.branch == "develop" || frames.test.status == "failed"`, true},
+		{`true`, true},
+	} {
+		expression, err := Parse(tc.expression)
+		if err != nil {
+			t.Fatalf("Failed to parse '%s': %s", tc.expression, err)
+		}
+		got, err := expression.Evaluate(vars)
+		if err != nil {
+			t.Fatalf("Failed to evaluate '%s': %s", tc.expression, err)
+		}
+		if got != tc.want {
+			t.Errorf("Expression '%s' want %v, got %v", tc.expression, tc.want, got)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, expression := range []string{
+		`event.branch ==`,
+		`event.branch + "a" == "maina"`,
+		`len(event.branch) == 4`,
+		`event.branch == 4`,
+		`frames[0] == "a"`,
+	} {
+		if _, err := Parse(expression); err == nil {
+			t.Errorf("Expected '%s' to be invalid", expression)
+		}
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	vars := Variables{
+		"event":  map[string]string{},
+		"frames": Variables{},
+	}
+	for _, source := range []string{
+		`event.branch`,
+		`frames.missing.status == "failed"`,
+		`unknown == "a"`,
+		`event == "a"`,
+		`!event.branch`,
+	} {
+		expression, err := Parse(source)
+		if err != nil {
+			t.Fatalf("Failed to parse '%s': %s", source, err)
+		}
+		if _, err := expression.Evaluate(vars); err == nil {
+			t.Errorf("Expected evaluation of '%s' to fail", source)
+		}
+	}
+}
+
+func TestValidator(t *testing.T) {
+	if err := (Validator{}).Validate(`event.branch == "main"`); err != nil {
+		t.Errorf("Valid expression rejected: %s", err)
+	}
+	if err := (Validator{}).Validate(`event.branch ==`); err == nil {
+		t.Errorf("Invalid expression accepted")
+	}
+}